@@ -23,6 +23,7 @@
 package container
 
 import (
+	"log/slog"
 	"net/http"
 	"text/template"
 )
@@ -31,39 +32,19 @@ import (
  This object models a Future, that contains a string. It is suitable for the
  use in conjunktion with the template engine.
 */
-type Element struct{
-	ch chan string
-}
-func NewElement() *Element {
-	return &Element{make(chan string,1)}
-}
-
-/*
- Returns the string that is contained within the Element object.
- This function will block until the content is offered using the
- Offer() method.
-*/
-func (e *Element) Get() string {
-	s := <- e.ch
-	e.ch <- s
-	return s
-}
+type Element = Future[string]
 
-/*
- Sets the content within the Element, so every consumer being blocked at
- Get() will unblock.  This method may only called once. Otherwise a deadlock
- occurs.
-*/
-func (e *Element) Offer(s string) *Element {
-	e.ch <- s
-	return e
-}
+func NewElement() *Element { return NewFuture[string]() }
 
 type Page struct{
 	Title *Element
 	Main *Element
 	SideBar []*Element
 	SiteID string
+
+	// The name of the template to execute (eg. a page of a tmplhelp.LayoutSet).
+	// If empty, the template of the container is executed.
+	Template string
 }
 func (p *Page) SiteActive(s string) bool {
 	return p.SiteID==s
@@ -82,22 +63,57 @@ var defaultElement = NewElement().Offer("")
 
 func DefaultElement() *Element { return defaultElement }
 
+// the data of a Page in Progressive mode.
+type progressivePage struct{
+	*Page
+	Main *slot[string]
+	SideBar []*slot[string]
+	Additional interface{}
+}
+
 type Container struct{
-	t *template.Template
+	t Templates
 	pg PageGen
 	adtn interface{}
+	mode RenderMode
+	errorPages
 }
 
-func NewContainer(t *template.Template,pg PageGen) *Container{
-	return &Container{t,pg,nil}
+func NewContainer(t Templates,pg PageGen) *Container{
+	return &Container{t:t,pg:pg}
 }
-func NewContainerWithAdditional(t *template.Template,pg PageGen,a interface{}) *Container{
-	return &Container{t,pg,a}
+func NewContainerWithAdditional(t Templates,pg PageGen,a interface{}) *Container{
+	return &Container{t:t,pg:pg,adtn:a}
 }
 
 func (b *Container) Additional(a interface{}){ b.adtn = a }
 
+// Sets the RenderMode. The default is Direct.
+func (b *Container) Mode(m RenderMode){ b.mode = m }
+
+/*
+ Sets the template, that renders the error page for the status code (with
+ ErrorData). The template for code 0 is used for all other codes.
+ */
+func (b *Container) ErrorTemplate(code int,t *template.Template){ b.setTemplate(code,t) }
+
+// Sets the logger for errors. The default is slog.Default().
+func (b *Container) Logger(l *slog.Logger){ b.logger = l }
+
 func (b *Container) ServeHTTP(resp http.ResponseWriter, req *http.Request){
-	b.t.Execute(resp,&adtnPage{b.pg.GetPage(req),b.adtn})
+	var p *Page
+	var err error
+	if pe,ok := b.pg.(PageGenErr); ok {
+		p,err = pe.GetPageErr(req)
+	} else {
+		p = b.pg.GetPage(req)
+	}
+	if err==nil && p==nil { err = &StatusError{Code:http.StatusNotFound} }
+	if err!=nil {
+		b.serveError(resp,req,err,b.adtn)
+		return
+	}
+	b.render(resp,req,b.t,p.Template,b.mode,&adtnPage{p,b.adtn},b.adtn,func(pr *progressive) interface{} {
+		return &progressivePage{p,newSlot(pr,p.Main),slots(pr,p.SideBar),b.adtn}
+	})
 }
-
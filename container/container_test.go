@@ -0,0 +1,78 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestElementOffer(t *testing.T) {
+	e := NewElement()
+	select {
+	case <- e.Done():
+		t.Fatal("a new Element is done")
+	default:
+	}
+	wg := new(sync.WaitGroup)
+	for i := 0; i<10; i++ {
+		wg.Add(1)
+		go func(){
+			defer wg.Done()
+			if v := e.Get(); v!="first" { t.Errorf("got %q",v) }
+		}()
+	}
+	time.Sleep(10*time.Millisecond)
+	e.Offer("first")
+	e.Offer("second")
+	e.OfferError(errors.New("late"))
+	wg.Wait()
+	if e.Err()!=nil { t.Errorf("Err = %v",e.Err()) }
+	<- e.Done()
+
+	if DefaultElement().Get()!="" { t.Errorf("DefaultElement is not empty") }
+}
+
+func TestElementError(t *testing.T) {
+	boom := errors.New("boom")
+	e := NewElement().OfferError(boom)
+	e.Offer("ignored")
+	if v,err := e.GetContext(context.Background()); v!="" || err!=boom { t.Errorf("got %q, %v",v,err) }
+	if e.Get()!="" || e.Err()!=boom { t.Errorf("got %q, %v",e.Get(),e.Err()) }
+}
+
+func TestElementTimeout(t *testing.T) {
+	e := NewElement()
+	start := time.Now()
+	if v := e.GetTimeout(20*time.Millisecond,"fallback"); v!="fallback" { t.Errorf("got %q",v) }
+	if d := time.Since(start); d<20*time.Millisecond { t.Errorf("returned after %v",d) }
+
+	ctx,cancel := context.WithTimeout(context.Background(),20*time.Millisecond)
+	defer cancel()
+	if _,err := e.GetContext(ctx); err!=context.DeadlineExceeded { t.Errorf("got %v",err) }
+
+	go func(){
+		time.Sleep(10*time.Millisecond)
+		e.Offer("v")
+	}()
+	if v := e.GetTimeout(time.Second,"fallback"); v!="v" { t.Errorf("got %q",v) }
+	// an offered value is returned even with a zero timeout
+	if v := e.GetTimeout(0,"fallback"); v!="v" { t.Errorf("got %q",v) }
+}
@@ -0,0 +1,121 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package container
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+/*
+ An error with an HTTP status code, eg. returned by GetPageErr:
+
+	return nil,&container.StatusError{Code:404}
+ */
+type StatusError struct{
+	Code int
+	Err  error // the cause, may be nil
+}
+
+func (e *StatusError) Error() string {
+	if e.Err==nil { return http.StatusText(e.Code) }
+	return http.StatusText(e.Code)+": "+e.Err.Error()
+}
+func (e *StatusError) Unwrap() error { return e.Err }
+
+// Returned by GetPageErr to redirect the client to URL.
+type Redirect struct{
+	URL  string
+	Code int // 302 if 0
+}
+
+func (r *Redirect) Error() string { return "redirect to "+r.URL }
+
+// A PageGen, that can fail. Errors other than StatusError and Redirect result in a 500.
+type PageGenErr interface{
+	GetPageErr (*http.Request)(*Page,error)
+}
+
+/*
+ Implements PageGen and PageGenErr. If a PageGen passed to NewContainer also
+ implements PageGenErr, GetPageErr is used instead of GetPage.
+ */
+type PageGenFunc func(*http.Request) (*Page,error)
+
+func (f PageGenFunc) GetPageErr(r *http.Request) (*Page,error) { return f(r) }
+func (f PageGenFunc) GetPage(r *http.Request) *Page {
+	p,_ := f(r)
+	return p
+}
+
+// The data passed to error templates.
+type ErrorData struct{
+	Code       int
+	StatusText string
+	Err        error
+	Request    *http.Request
+	Additional interface{}
+}
+
+// the error handling shared by Container and HTMLContainer.
+type errorPages struct{
+	templates map[int]executor
+	logger    *slog.Logger
+}
+
+func (ep *errorPages) setTemplate(code int,t executor) {
+	if ep.templates==nil { ep.templates = make(map[int]executor) }
+	ep.templates[code] = t
+}
+
+func (ep *errorPages) log() *slog.Logger {
+	if ep.logger==nil { return slog.Default() }
+	return ep.logger
+}
+
+// sends the error page for err.
+func (ep *errorPages) serveError(resp http.ResponseWriter,req *http.Request,err error,adtn interface{}) {
+	var rd *Redirect
+	if errors.As(err,&rd) {
+		code := rd.Code
+		if code==0 { code = http.StatusFound }
+		http.Redirect(resp,req,rd.URL,code)
+		return
+	}
+	code := http.StatusInternalServerError
+	var se *StatusError
+	if errors.As(err,&se) { code = se.Code }
+	if code>=500 {
+		ep.log().ErrorContext(req.Context(),"container: page failed","method",req.Method,"path",req.URL.Path,"status",code,"error",err)
+	}
+	t,ok := ep.templates[code]
+	if !ok { t,ok = ep.templates[0] }
+	if ok {
+		buf := new(bytes.Buffer)
+		e := t.Execute(buf,&ErrorData{code,http.StatusText(code),err,req,adtn})
+		if e==nil {
+			resp.Header().Set("Content-Type","text/html; charset=utf-8")
+			resp.WriteHeader(code)
+			buf.WriteTo(resp)
+			return
+		}
+		ep.log().ErrorContext(req.Context(),"container: error template failed","method",req.Method,"path",req.URL.Path,"status",code,"error",e)
+	}
+	http.Error(resp,http.StatusText(code),code)
+}
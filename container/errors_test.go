@@ -0,0 +1,85 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package container
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestErrorPages(t *testing.T) {
+	boom := errors.New("boom")
+	tpl := template.Must(template.New("").Parse(`{{.Main.Get}}`))
+	c := NewContainerWithAdditional(tpl,PageGenFunc(func(r *http.Request) (*Page,error) {
+		switch r.URL.Path {
+		case "/ok":
+			return &Page{Title:DefaultElement(),Main:NewElement().Offer("ok")},nil
+		case "/gone":
+			return nil,&StatusError{Code:410}
+		case "/moved":
+			return nil,&Redirect{URL:"/ok",Code:301}
+		case "/login":
+			return nil,fmt.Errorf("wrapped: %w",&Redirect{URL:"/in"})
+		case "/boom":
+			return nil,boom
+		case "/forbidden":
+			return nil,&StatusError{Code:403,Err:boom}
+		}
+		return nil,nil
+	}),"site")
+	log := new(bytes.Buffer)
+	c.Logger(slog.New(slog.NewTextHandler(log,nil)))
+	c.ErrorTemplate(404,template.Must(template.New("").Parse(`not found: {{.Request.URL.Path}} {{.Additional}}`)))
+	c.ErrorTemplate(0,template.Must(template.New("").Parse(`{{.Code}} {{.StatusText}}`)))
+	c.ErrorTemplate(403,template.Must(template.New("").Parse(`{{.Missing}}`)))
+
+	tests := []struct{
+		path,body,location string
+		code               int
+	}{
+		{"/ok","ok","",200},
+		{"/missing","not found: /missing site","",404},
+		{"/gone","410 Gone","",410},
+		{"/moved","","/ok",301},
+		{"/login","","/in",302},
+		{"/boom","500 Internal Server Error","",500},
+		// the error template fails
+		{"/forbidden","Forbidden\n","",403},
+	}
+	for _,tt := range tests {
+		resp,body := get(t,c,tt.path)
+		if resp.StatusCode!=tt.code || (tt.location=="" && body!=tt.body) || resp.Header.Get("Location")!=tt.location {
+			t.Errorf("%s: got %d %q %q",tt.path,resp.StatusCode,body,resp.Header.Get("Location"))
+		}
+	}
+	l := log.String()
+	if !strings.Contains(l,"path=/boom status=500 error=boom") || !strings.Contains(l,"error template failed") { t.Errorf("log:\n%s",l) }
+	if strings.Contains(l,"/gone") { t.Errorf("a 410 was logged:\n%s",l) }
+}
+
+func TestStatusError(t *testing.T) {
+	boom := errors.New("boom")
+	e := error(&StatusError{Code:404,Err:boom})
+	if e.Error()!="Not Found: boom" || !errors.Is(e,boom) { t.Errorf("got %v",e) }
+	if e := (&StatusError{Code:500}); e.Error()!="Internal Server Error" { t.Errorf("got %v",e) }
+}
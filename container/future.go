@@ -0,0 +1,213 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+/*
+ A Future contains a value of type T, that is offered asynchronously. In a
+ template, the value is accessed with Get, so structured data can be used
+ directly, eg.
+
+	{{range .Additional.Items.Get}}<li>{{.Name}}</li>{{end}}
+*/
+type Future[T any] struct{
+	once sync.Once
+	done chan struct{}
+	v    T
+	err  error
+}
+
+func NewFuture[T any]() *Future[T] {
+	return &Future[T]{done:make(chan struct{})}
+}
+
+// Returns a Future, that already contains v.
+func Resolved[T any](v T) *Future[T] { return NewFuture[T]().Offer(v) }
+
+// Returns a Future, that already failed with err.
+func Failed[T any](err error) *Future[T] { return NewFuture[T]().OfferError(err) }
+
+/*
+ Returns the value that is contained within the Future.
+ This function will block until the value is offered using the
+ Offer() or OfferError() method.
+*/
+func (f *Future[T]) Get() T {
+	<- f.done
+	return f.v
+}
+
+/*
+ Like Get, but returns ctx.Err(), if ctx is done before the value is
+ offered. Otherwise the error passed to OfferError (or nil) is returned.
+*/
+func (f *Future[T]) GetContext(ctx context.Context) (T,error) {
+	select {
+	case <- f.done:
+		return f.v,f.err
+	case <- ctx.Done():
+		var zero T
+		return zero,ctx.Err()
+	}
+}
+
+/*
+ Like Get, but returns fallback, if the value has not been offered within
+ d, so that a stuck producer can not hang a template, eg.:
+
+	{{.Main.GetTimeout 2000000000 "Not available."}}
+*/
+func (f *Future[T]) GetTimeout(d time.Duration,fallback T) T {
+	select {
+	case <- f.done:
+		return f.v
+	default:
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <- f.done:
+		return f.v
+	case <- t.C:
+		return fallback
+	}
+}
+
+// Blocks like Get and returns the error passed to OfferError (or nil).
+func (f *Future[T]) Err() error {
+	<- f.done
+	return f.err
+}
+
+// Returns a channel, that is closed, once the value is offered.
+func (f *Future[T]) Done() <-chan struct{} { return f.done }
+
+func (f *Future[T]) offer(v T,err error) *Future[T] {
+	f.once.Do(func(){
+		f.v,f.err = v,err
+		close(f.done)
+	})
+	return f
+}
+
+/*
+ Sets the value within the Future, so every consumer being blocked at
+ Get() will unblock. Only the first call to Offer or OfferError has an
+ effect, subsequent calls are ignored.
+*/
+func (f *Future[T]) Offer(v T) *Future[T] { return f.offer(v,nil) }
+
+/*
+ Marks the Future as failed: Get returns the zero value and GetContext and
+ Err return err. Like Offer, only the first call has an effect.
+*/
+func (f *Future[T]) OfferError(err error) *Future[T] {
+	var zero T
+	return f.offer(zero,err)
+}
+
+/*
+ Returns a Future, that contains the result of fn applied to the value of f.
+ If f fails, the returned Future fails with the same error and fn is not
+ called.
+*/
+func Then[T,U any](f *Future[T],fn func(T) (U,error)) *Future[U] {
+	r := NewFuture[U]()
+	go func(){
+		<- f.done
+		if f.err!=nil {
+			r.OfferError(f.err)
+			return
+		}
+		r.offer(fn(f.v))
+	}()
+	return r
+}
+
+// Like Then, for functions, that can not fail.
+func Map[T,U any](f *Future[T],fn func(T) U) *Future[U] {
+	return Then(f,func(v T) (U,error) { return fn(v),nil })
+}
+
+/*
+ Returns a Future, that contains the values of all fs (in order), once they
+ are offered. It fails as soon as one of them fails.
+*/
+func All[T any](fs ...*Future[T]) *Future[[]T] {
+	r := NewFuture[[]T]()
+	vs := make([]T,len(fs))
+	wg := new(sync.WaitGroup)
+	wg.Add(len(fs))
+	for i,f := range fs {
+		go func(i int,f *Future[T]){
+			defer wg.Done()
+			select {
+			case <- f.done:
+			case <- r.done:
+				return
+			}
+			if f.err!=nil {
+				r.OfferError(f.err)
+				return
+			}
+			vs[i] = f.v
+		}(i,f)
+	}
+	go func(){
+		wg.Wait()
+		r.Offer(vs)
+	}()
+	return r
+}
+
+/*
+ Returns a Future, that contains the first value offered by one of fs. If
+ all of them fail, it fails with their errors joined.
+*/
+func Any[T any](fs ...*Future[T]) *Future[T] {
+	r := NewFuture[T]()
+	if len(fs)==0 { return r.OfferError(errors.New("container: Any of no futures")) }
+	errs := make([]error,len(fs))
+	wg := new(sync.WaitGroup)
+	wg.Add(len(fs))
+	for i,f := range fs {
+		go func(i int,f *Future[T]){
+			defer wg.Done()
+			select {
+			case <- f.done:
+			case <- r.done:
+				return
+			}
+			if f.err!=nil {
+				errs[i] = f.err
+				return
+			}
+			r.Offer(f.v)
+		}(i,f)
+	}
+	go func(){
+		wg.Wait()
+		r.OfferError(errors.Join(errs...))
+	}()
+	return r
+}
@@ -0,0 +1,75 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package container
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestThen(t *testing.T) {
+	f := NewFuture[int]()
+	s := Map(Then(f,func(v int) (int,error) { return v*2,nil }),strconv.Itoa)
+	f.Offer(21)
+	if v := s.Get(); v!="42" { t.Errorf("got %q",v) }
+
+	boom := errors.New("boom")
+	called := false
+	g := Then(Failed[int](boom),func(v int) (int,error) {
+		called = true
+		return v,nil
+	})
+	if g.Err()!=boom || called { t.Errorf("got %v, called %v",g.Err(),called) }
+	if e := Then(Resolved(1),func(int) (string,error) { return "",boom }).Err(); e!=boom { t.Errorf("got %v",e) }
+}
+
+func TestAll(t *testing.T) {
+	fs := []*Future[int]{NewFuture[int](),NewFuture[int](),NewFuture[int]()}
+	all := All(fs...)
+	// offered out of order
+	fs[2].Offer(3)
+	fs[0].Offer(1)
+	fs[1].Offer(2)
+	if v := all.Get(); !reflect.DeepEqual(v,[]int{1,2,3}) { t.Errorf("got %v",v) }
+	if v := All[int]().Get(); len(v)!=0 { t.Errorf("All of nothing: %v",v) }
+
+	boom := errors.New("boom")
+	pending := NewFuture[int]()
+	all = All(Resolved(1),pending,Failed[int](boom))
+	select {
+	case <- all.Done():
+	case <- time.After(time.Second):
+		t.Fatal("All waits for the pending future after a failure")
+	}
+	if all.Err()!=boom { t.Errorf("got %v",all.Err()) }
+}
+
+func TestAny(t *testing.T) {
+	a,b := NewFuture[string](),NewFuture[string]()
+	first := Any(a,b)
+	b.OfferError(errors.New("b failed"))
+	a.Offer("a")
+	if v,e := first.Get(),first.Err(); v!="a" || e!=nil { t.Errorf("got %q, %v",v,e) }
+
+	e1,e2 := errors.New("one"),errors.New("two")
+	none := Any(Failed[string](e1),Failed[string](e2))
+	if e := none.Err(); !errors.Is(e,e1) || !errors.Is(e,e2) { t.Errorf("got %v",e) }
+	if Any[string]().Err()==nil { t.Errorf("Any of nothing succeeded") }
+}
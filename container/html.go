@@ -0,0 +1,140 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package container
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+)
+
+/*
+ A Fragment is a Future, that contains HTML, which is inserted into an
+ html/template without escaping. Only offer sanitized (see
+ htmlscrape.Sanitize) or trusted HTML to a Fragment; Plain text belongs into
+ an Element, which is escaped.
+*/
+type Fragment = Future[template.HTML]
+
+func NewFragment() *Fragment { return NewFuture[template.HTML]() }
+
+var defaultFragment = NewFragment().Offer("")
+
+func DefaultFragment() *Fragment { return defaultFragment }
+
+// The counterpart of Page for the HTMLContainer.
+type HTMLPage struct{
+	Title *Element
+	Main *Fragment
+	SideBar []*Fragment
+	SiteID string
+
+	// The name of the template to execute (eg. a page of a tmplhelp.LayoutSet).
+	// If empty, the template of the container is executed.
+	Template string
+}
+func (p *HTMLPage) SiteActive(s string) bool {
+	return p.SiteID==s
+}
+
+type adtnHTMLPage struct{
+	*HTMLPage
+	Additional interface{}
+}
+
+type HTMLPageGen interface{
+	GetHTMLPage (*http.Request)*HTMLPage
+}
+
+// The counterpart of PageGenErr for the HTMLContainer.
+type HTMLPageGenErr interface{
+	GetHTMLPageErr (*http.Request)(*HTMLPage,error)
+}
+
+// Implements HTMLPageGen and HTMLPageGenErr.
+type HTMLPageGenFunc func(*http.Request) (*HTMLPage,error)
+
+func (f HTMLPageGenFunc) GetHTMLPageErr(r *http.Request) (*HTMLPage,error) { return f(r) }
+func (f HTMLPageGenFunc) GetHTMLPage(r *http.Request) *HTMLPage {
+	p,_ := f(r)
+	return p
+}
+
+/*
+ Like Container, but uses html/template, so that the content is escaped
+ according to its context. The Title and every other Element are escaped,
+ the Fragments are inserted as they are. So Elements are text only here:
+ The HTML, that webscrape offers to a QueryElement.Element, would be shown
+ as markup, it must be offered to a Fragment instead.
+*/
+type HTMLContainer struct{
+	t Templates
+	pg HTMLPageGen
+	adtn interface{}
+	mode RenderMode
+	errorPages
+}
+
+/*
+ Creates an HTMLContainer. t must be an *html/template.Template or a set of
+ html/templates (see IsHTML), otherwise NewHTMLContainer panics: A
+ text/template would insert the Elements without escaping.
+ */
+func NewHTMLContainer(t Templates,pg HTMLPageGen) *HTMLContainer{
+	return NewHTMLContainerWithAdditional(t,pg,nil)
+}
+func NewHTMLContainerWithAdditional(t Templates,pg HTMLPageGen,a interface{}) *HTMLContainer{
+	if !IsHTML(t) { panic("container: the templates of an HTMLContainer must be html/templates") }
+	return &HTMLContainer{t:t,pg:pg,adtn:a}
+}
+
+func (b *HTMLContainer) Additional(a interface{}){ b.adtn = a }
+
+// Sets the RenderMode. The default is Direct.
+func (b *HTMLContainer) Mode(m RenderMode){ b.mode = m }
+
+// the data of an HTMLPage in Progressive mode.
+type progressiveHTMLPage struct{
+	*HTMLPage
+	Main *slot[template.HTML]
+	SideBar []*slot[template.HTML]
+	Additional interface{}
+}
+
+// See Container.ErrorTemplate.
+func (b *HTMLContainer) ErrorTemplate(code int,t *template.Template){ b.setTemplate(code,t) }
+
+// Sets the logger for errors. The default is slog.Default().
+func (b *HTMLContainer) Logger(l *slog.Logger){ b.logger = l }
+
+func (b *HTMLContainer) ServeHTTP(resp http.ResponseWriter, req *http.Request){
+	var p *HTMLPage
+	var err error
+	if pe,ok := b.pg.(HTMLPageGenErr); ok {
+		p,err = pe.GetHTMLPageErr(req)
+	} else {
+		p = b.pg.GetHTMLPage(req)
+	}
+	if err==nil && p==nil { err = &StatusError{Code:http.StatusNotFound} }
+	if err!=nil {
+		b.serveError(resp,req,err,b.adtn)
+		return
+	}
+	b.render(resp,req,b.t,p.Template,b.mode,&adtnHTMLPage{p,b.adtn},b.adtn,func(pr *progressive) interface{} {
+		return &progressiveHTMLPage{p,newSlot(pr,p.Main),slots(pr,p.SideBar),b.adtn}
+	})
+}
@@ -0,0 +1,49 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package container
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serves h and returns the response to a GET of path.
+func get(t *testing.T,h http.Handler,path string) (*http.Response,string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w,httptest.NewRequest("GET",path,nil))
+	resp := w.Result()
+	b,_ := io.ReadAll(resp.Body)
+	return resp,string(b)
+}
+
+func TestHTMLContainer(t *testing.T) {
+	tpl := template.Must(template.New("page").Parse(`<title>{{.Title.Get}}</title><main>{{.Main.Get}}</main>{{range .SideBar}}<aside>{{.Get}}</aside>{{end}}<a href="{{.Additional}}">x</a>`))
+	c := NewHTMLContainerWithAdditional(tpl,HTMLPageGenFunc(func(r *http.Request) (*HTMLPage,error) {
+		return &HTMLPage{
+			Title:NewElement().Offer("<b>Title</b>"),
+			Main:NewFragment().Offer("<p>main</p>"),
+			SideBar:[]*Fragment{DefaultFragment(),NewFragment().Offer("<i>side</i>")},
+		},nil
+	}),"javascript:alert(1)")
+	_,body := get(t,c,"/")
+	want := `<title>&lt;b&gt;Title&lt;/b&gt;</title><main><p>main</p></main><aside></aside><aside><i>side</i></aside><a href="#ZgotmplZ">x</a>`
+	if body!=want { t.Errorf("got  %s\nwant %s",body,want) }
+}
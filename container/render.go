@@ -0,0 +1,244 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package container
+
+import (
+	htmltemplate "html/template"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// Controls, how a Container sends the page.
+type RenderMode int
+
+const (
+	// The template is executed directly into the response. Every Get blocks
+	// the response, until the Element is offered.
+	Direct RenderMode = iota
+
+	// The page is rendered completely, before it is sent. If the template
+	// fails, a clean "500 Internal Server Error" is sent instead.
+	Buffered
+
+	/*
+	 The page shell is sent right away: Every Main and SideBar Element, that
+	 has not been offered yet, is rendered as an empty placeholder. Once the
+	 Element is offered, its content is streamed at the end of the page
+	 together with an inline script, that moves it into the placeholder. The
+	 Title is awaited, as it can not be replaced this way. Like in Buffered
+	 mode, a template error results in a clean 500.
+
+	 The Elements must be rendered with {{.Main.Get}} in an HTML element
+	 context, eg. <main>{{.Main.Get}}</main>.
+	 */
+	Progressive
+)
+
+// the script, that moves the content of the <template id="sl-c-N"> into the placeholder "sl-N".
+const swapScript = `<script>function scraplandSwap(n){var t=document.getElementById("sl-c-"+n),p=document.getElementById("sl-"+n);if(t&&p){p.replaceWith(t.content);t.remove()}}</script>`
+
+// an Element, whose content is awaited, after the shell has been sent.
+type pending struct{
+	id    int
+	done  <-chan struct{}
+	value func() string
+}
+
+// the state of a progressive rendering.
+type progressive struct{
+	pending []pending
+}
+
+/*
+ A slot stands in for an Element in Progressive mode. It has the methods of
+ the Element, but those, that would block, return the content if it is
+ available, and a placeholder otherwise.
+ */
+type slot[T ~string] struct{
+	f *Future[T]
+	p *progressive
+}
+
+// reports, whether f has been offered.
+func offered[T any](f *Future[T]) bool {
+	select {
+	case <- f.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// returns a placeholder for the content of f, that is sent, once f is offered.
+func (s *slot[T]) later(f *Future[T]) T {
+	if offered(f) { return f.v }
+	id := len(s.p.pending)
+	s.p.pending = append(s.p.pending,pending{id,f.done,func() string { return string(f.v) }})
+	return T(fmt.Sprintf(`<span id="sl-%d" hidden></span>`,id))
+}
+
+func (s *slot[T]) Get() T { return s.later(s.f) }
+
+// The fallback replaces the placeholder, if the Element is not offered within d.
+func (s *slot[T]) GetTimeout(d time.Duration,fallback T) T {
+	if offered(s.f) { return s.f.v }
+	g := NewFuture[T]()
+	go func(){ g.Offer(s.f.GetTimeout(d,fallback)) }()
+	return s.later(g)
+}
+
+// The placeholder stays empty, if ctx is done before the Element is offered.
+func (s *slot[T]) GetContext(ctx context.Context) (T,error) {
+	if offered(s.f) { return s.f.v,s.f.err }
+	g := NewFuture[T]()
+	go func(){
+		v,_ := s.f.GetContext(ctx)
+		g.Offer(v)
+	}()
+	return s.later(g),nil
+}
+
+// Blocks like Element.Err.
+func (s *slot[T]) Err() error { return s.f.Err() }
+func (s *slot[T]) Done() <-chan struct{} { return s.f.Done() }
+func (s *slot[T]) Offer(v T) *Future[T] { return s.f.Offer(v) }
+func (s *slot[T]) OfferError(err error) *Future[T] { return s.f.OfferError(err) }
+
+// returns nil for a nil Future, so that {{if .Main}} works as usual.
+func newSlot[T ~string](p *progressive,f *Future[T]) *slot[T] {
+	if f==nil { return nil }
+	return &slot[T]{f,p}
+}
+
+func slots[T ~string](p *progressive,fs []*Future[T]) []*slot[T] {
+	l := make([]*slot[T],len(fs))
+	for i,f := range fs { l[i] = newSlot(p,f) }
+	return l
+}
+
+// a start or end tag of a template element, in any case.
+var templateTag = regexp.MustCompile(`(?i)<(/?template)`)
+
+// escapes the template tags within s, that would end the <template> around it early.
+func escapeTemplate(s string) string { return templateTag.ReplaceAllString(s,"&lt;$1") }
+
+/*
+ Sends the executed shell and streams the pending contents as they are
+ offered. It stops early, if the client goes away.
+ */
+func (p *progressive) send(resp http.ResponseWriter,req *http.Request,shell *bytes.Buffer) {
+	rc := http.NewResponseController(resp)
+	resp.Header().Set("Content-Type","text/html; charset=utf-8")
+	resp.Header().Set("X-Accel-Buffering","no")
+	shell.WriteTo(resp)
+	if len(p.pending)==0 { return }
+	io.WriteString(resp,swapScript)
+	rc.Flush()
+
+	ready := make(chan pending,len(p.pending))
+	for _,pd := range p.pending {
+		go func(){
+			select {
+			case <- pd.done:
+				ready <- pd
+			case <- req.Context().Done():
+			}
+		}()
+	}
+	for range p.pending {
+		var pd pending
+		select {
+		case pd = <- ready:
+		case <- req.Context().Done():
+			return
+		}
+		fmt.Fprintf(resp,`<template id="sl-c-%d">%s</template><script>scraplandSwap(%d)</script>`,pd.id,escapeTemplate(pd.value()),pd.id)
+		if rc.Flush()!=nil { return }
+	}
+}
+
+// an executed template, either a text/template or an html/template.
+type executor interface{
+	Execute(w io.Writer,data interface{}) error
+}
+
+/*
+ The templates of a container: A *template.Template (text/template for a
+ Container, html/template for an HTMLContainer) or a set of templates, such as
+ tmplhelp.LayoutSet. If a Page has a Template name, it is executed with
+ ExecuteTemplate, otherwise Execute is used.
+ */
+type Templates interface{
+	executor
+	ExecuteTemplate(w io.Writer,name string,data interface{}) error
+}
+
+/*
+ Implemented by sets of templates (eg. tmplhelp.LayoutSet), that may consist
+ of html/templates.
+ */
+type HTMLTemplates interface{
+	Templates
+	// Reports, whether the templates are html/templates.
+	HTML() bool
+}
+
+// Reports, whether t is an html/template or a set of html/templates, that escape their data.
+func IsHTML(t Templates) bool {
+	switch t := t.(type) {
+	case *htmltemplate.Template:
+		return true
+	case HTMLTemplates:
+		return t.HTML()
+	}
+	return false
+}
+
+// executes t or its template name.
+func execute(t Templates,name string,w io.Writer,data interface{}) error {
+	if name=="" { return t.Execute(w,data) }
+	return t.ExecuteTemplate(w,name,data)
+}
+
+// renders data with t according to mode. shell builds the data for Progressive mode.
+func (ep *errorPages) render(resp http.ResponseWriter,req *http.Request,t Templates,name string,mode RenderMode,data interface{},adtn interface{},shell func(p *progressive) interface{}) {
+	switch mode {
+	case Buffered,Progressive:
+		p := new(progressive)
+		if mode==Progressive { data = shell(p) }
+		buf := new(bytes.Buffer)
+		if e := execute(t,name,buf,data); e!=nil {
+			ep.serveError(resp,req,e,adtn)
+			return
+		}
+		if mode==Buffered {
+			buf.WriteTo(resp)
+			return
+		}
+		p.send(resp,req,buf)
+	default:
+		// the response has been started, so only logging is possible.
+		if e := execute(t,name,resp,data); e!=nil {
+			ep.log().ErrorContext(req.Context(),"container: template failed","method",req.Method,"path",req.URL.Path,"error",e)
+		}
+	}
+}
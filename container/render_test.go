@@ -0,0 +1,100 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func pageGen(p *Page) PageGen { return PageGenFunc(func(*http.Request) (*Page,error) { return p,nil }) }
+
+func TestBuffered(t *testing.T) {
+	tpl := template.Must(template.New("").Parse(`<h1>{{.Title.Get}}</h1>{{.Main.Get}}{{if .SiteActive "x"}}x{{end}}`))
+	c := NewContainer(tpl,pageGen(&Page{Title:NewElement().Offer("T"),Main:NewElement().Offer("<p>m</p>"),SiteID:"x"}))
+	for _,m := range []RenderMode{Direct,Buffered} {
+		c.Mode(m)
+		if _,body := get(t,c,"/"); body!="<h1>T</h1><p>m</p>x" { t.Errorf("mode %d: got %s",m,body) }
+	}
+
+	tpl = template.Must(template.New("").Parse(`partial output {{.Missing.Get}}`))
+	c = NewContainer(tpl,pageGen(&Page{Title:DefaultElement(),Main:DefaultElement()}))
+	c.Mode(Buffered)
+	c.Logger(slog.New(slog.NewTextHandler(io.Discard,nil)))
+	resp,body := get(t,c,"/")
+	if resp.StatusCode!=500 || strings.Contains(body,"partial") { t.Errorf("got %d %s",resp.StatusCode,body) }
+}
+
+func TestProgressive(t *testing.T) {
+	tpl := template.Must(template.New("").Parse(`<title>{{.Title.Get}}</title><main>{{.Main.Get}}</main>{{range .SideBar}}<aside>{{.GetTimeout 10000000 "fallback"}}</aside>{{end}}{{if .Main.Done}}{{end}}`))
+	title,main := NewElement(),NewElement()
+	c := NewContainer(tpl,pageGen(&Page{Title:title,Main:main,SideBar:[]*Element{NewElement(),NewElement().Offer("ready")}}))
+	c.Mode(Progressive)
+	go func(){
+		time.Sleep(10*time.Millisecond)
+		title.Offer("T")
+		time.Sleep(10*time.Millisecond)
+		main.Offer("<p>late</template></TEMPLATE ><template>x</p>")
+	}()
+	_,body := get(t,c,"/")
+	for _,want := range []string{
+		`<title>T</title><main><span id="sl-0" hidden></span></main><aside><span id="sl-1" hidden></span></aside><aside>ready</aside>`,
+		`<template id="sl-c-1">fallback</template><script>scraplandSwap(1)</script>`,
+		`<template id="sl-c-0"><p>late&lt;/template>&lt;/TEMPLATE >&lt;template>x</p></template><script>scraplandSwap(0)</script>`,
+	} {
+		if !strings.Contains(body,want) { t.Errorf("got  %s\nwant %s",body,want) }
+	}
+}
+
+func TestProgressiveCanceled(t *testing.T) {
+	tpl := template.Must(template.New("").Parse(`<main>{{.Main.Get}}</main>`))
+	c := NewContainer(tpl,pageGen(&Page{Title:DefaultElement(),Main:NewElement()}))
+	c.Mode(Progressive)
+	ctx,cancel := context.WithTimeout(context.Background(),20*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	w := httptest.NewRecorder()
+	go func(){
+		c.ServeHTTP(w,httptest.NewRequest("GET","/",nil).WithContext(ctx))
+		close(done)
+	}()
+	select {
+	case <- done:
+	case <- time.After(time.Second):
+		t.Fatal("the response did not end with the request")
+	}
+	if !strings.Contains(w.Body.String(),`<span id="sl-0" hidden></span>`) { t.Errorf("got %s",w.Body) }
+}
+
+func TestEscapeTemplate(t *testing.T) {
+	for in,want := range map[string]string{
+		"a</template>b":"a&lt;/template>b",
+		"</TeMpLaTe":"&lt;/TeMpLaTe",
+		"<template><p></p>":"&lt;template><p></p>",
+		"</templates":"&lt;/templates",
+		"</p>":"</p>",
+	} {
+		if got := escapeTemplate(in); got!=want { t.Errorf("%s: got %s, want %s",in,got,want) }
+	}
+}
@@ -0,0 +1,108 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package container
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ Serves the static files (eg. CSS, scripts and images) of fsys, such as an
+ embed.FS or a tmplhelp.Overlay. Unlike http.FileServerFS, it does not list
+ directories and it sends an ETag (derived from the content), as embedded
+ files have no modification time. Mount it with http.StripPrefix:
+
+	mux.Handle("/static/",http.StripPrefix("/static/",container.NewStatic(fsys)))
+ */
+type Static struct{
+	FS fs.FS
+
+	// If not 0, a "Cache-Control: public, max-age=..." header is sent.
+	MaxAge time.Duration
+
+	mutex sync.Mutex
+	etags map[string]string
+}
+
+func NewStatic(fsys fs.FS) *Static {
+	return &Static{FS:fsys}
+}
+
+// the ETag of the file name, cached by its modification time and size.
+func (s *Static) etag(name string,fi fs.FileInfo,f fs.File) (string,io.ReadSeeker,error) {
+	key := fmt.Sprintf("%s\x00%d\x00%d",name,fi.ModTime().UnixNano(),fi.Size())
+	s.mutex.Lock()
+	tag,ok := s.etags[key]
+	s.mutex.Unlock()
+	rs,seekable := f.(io.ReadSeeker)
+	if ok && seekable { return tag,rs,nil }
+
+	b,e := io.ReadAll(f)
+	if e!=nil { return "",nil,e }
+	sum := sha256.Sum256(b)
+	tag = `"`+hex.EncodeToString(sum[:16])+`"`
+	s.mutex.Lock()
+	if s.etags==nil { s.etags = make(map[string]string) }
+	s.etags[key] = tag
+	s.mutex.Unlock()
+	return tag,bytes.NewReader(b),nil
+}
+
+func (s *Static) ServeHTTP(resp http.ResponseWriter,req *http.Request) {
+	if req.Method!=http.MethodGet && req.Method!=http.MethodHead {
+		resp.Header().Set("Allow","GET, HEAD")
+		http.Error(resp,http.StatusText(http.StatusMethodNotAllowed),http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+req.URL.Path),"/")
+	if name=="" { name = "." }
+	f,e := s.FS.Open(name)
+	if e==nil {
+		if fi,_ := f.Stat(); fi!=nil && fi.IsDir() {
+			f.Close()
+			f,e = s.FS.Open(path.Join(name,"index.html"))
+		}
+	}
+	if e!=nil {
+		http.NotFound(resp,req)
+		return
+	}
+	defer f.Close()
+	fi,e := f.Stat()
+	if e!=nil || fi.IsDir() {
+		http.NotFound(resp,req)
+		return
+	}
+	tag,rs,e := s.etag(name,fi,f)
+	if e!=nil {
+		http.Error(resp,http.StatusText(http.StatusInternalServerError),http.StatusInternalServerError)
+		return
+	}
+	resp.Header().Set("ETag",tag)
+	if s.MaxAge>0 { resp.Header().Set("Cache-Control",fmt.Sprintf("public, max-age=%d",int(s.MaxAge.Seconds()))) }
+	http.ServeContent(resp,req,fi.Name(),fi.ModTime(),rs)
+}
@@ -0,0 +1,75 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package container
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var staticFS = fstest.MapFS{
+	"css/site.css":    {Data:[]byte("body{}")},
+	"docs/index.html": {Data:[]byte("<h1>docs</h1>")},
+	"img/a.png":       {Data:[]byte("png")},
+}
+
+func TestStatic(t *testing.T) {
+	s := NewStatic(staticFS)
+	s.MaxAge = time.Hour
+	resp,body := get(t,s,"/css/site.css")
+	tag := resp.Header.Get("ETag")
+	if resp.StatusCode!=200 || body!="body{}" || tag=="" { t.Fatalf("got %d %q, ETag %q",resp.StatusCode,body,tag) }
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct,"text/css") { t.Errorf("Content-Type = %s",ct) }
+	if cc := resp.Header.Get("Cache-Control"); cc!="public, max-age=3600" { t.Errorf("Cache-Control = %s",cc) }
+
+	resp,_ = get(t,s,"/css/../css/site.css")
+	if resp.Header.Get("ETag")!=tag { t.Errorf("the ETag changed: %s",resp.Header.Get("ETag")) }
+
+	req := httptest.NewRequest("GET","/css/site.css",nil)
+	req.Header.Set("If-None-Match",tag)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w,req)
+	if w.Code!=http.StatusNotModified || w.Body.Len()!=0 { t.Errorf("If-None-Match: got %d %q",w.Code,w.Body) }
+
+	if resp,body = get(t,s,"/docs/"); resp.StatusCode!=200 || body!="<h1>docs</h1>" { t.Errorf("index: got %d %q",resp.StatusCode,body) }
+	for _,p := range []string{"/","/img/","/missing.css","/../css/site.css/x"} {
+		if resp,body = get(t,s,p); resp.StatusCode!=404 { t.Errorf("%s: got %d %q",p,resp.StatusCode,body) }
+	}
+	if _,body = get(t,s,"/img/"); strings.Contains(body,"a.png") { t.Errorf("listed a directory: %s",body) }
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w,httptest.NewRequest("POST","/css/site.css",nil))
+	if w.Code!=http.StatusMethodNotAllowed || w.Header().Get("Allow")!="GET, HEAD" { t.Errorf("POST: got %d, Allow %q",w.Code,w.Header().Get("Allow")) }
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w,httptest.NewRequest("HEAD","/css/site.css",nil))
+	if w.Code!=200 || w.Body.Len()!=0 || w.Header().Get("ETag")!=tag { t.Errorf("HEAD: got %d %q",w.Code,w.Body) }
+}
+
+func TestStaticETagChanges(t *testing.T) {
+	fsys := fstest.MapFS{"a.txt":{Data:[]byte("one")}}
+	s := NewStatic(fsys)
+	resp,_ := get(t,s,"/a.txt")
+	tag := resp.Header.Get("ETag")
+	fsys["a.txt"] = &fstest.MapFile{Data:[]byte("two"),ModTime:time.Unix(1,0)}
+	resp,body := get(t,s,"/a.txt")
+	if body!="two" || resp.Header.Get("ETag")==tag { t.Errorf("got %q, ETag %s",body,resp.Header.Get("ETag")) }
+}
@@ -0,0 +1,394 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+/*
+ A polite web crawler built on webscrape and htmlscrape. Starting from seed
+ URLs, it follows the links within the scope, respects robots.txt (including
+ Crawl-delay), limits the request rate per host and hands every fetched page
+ to a callback. The frontier can be persisted to disk, so that a crawl can be
+ resumed.
+ */
+package crawler
+
+import (
+	"github.com/maxymania/scrapland/htmlscrape"
+	"github.com/maxymania/scrapland/webscrape"
+	"golang.org/x/net/html"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ Returns the normalized form of u: The scheme and host are lower-cased,
+ default ports and the fragment are removed, the path is cleaned and the
+ query parameters are sorted.
+ */
+func Normalize(u *url.URL) *url.URL {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = strings.ToLower(n.Host)
+	if (n.Scheme=="http" && strings.HasSuffix(n.Host,":80")) || (n.Scheme=="https" && strings.HasSuffix(n.Host,":443")) {
+		n.Host = n.Host[:strings.LastIndexByte(n.Host,':')]
+	}
+	n.Fragment = ""
+	n.RawFragment = ""
+	n.User = nil
+	if n.Path=="" { n.Path = "/" }
+	// resolves "." and ".." segments
+	n = *n.ResolveReference(&url.URL{Path:n.Path,RawPath:n.RawPath,RawQuery:n.RawQuery})
+	if n.RawQuery!="" {
+		q := strings.Split(n.RawQuery,"&")
+		sort.Strings(q)
+		n.RawQuery = strings.Join(q,"&")
+	}
+	return &n
+}
+
+// Decides, whether a URL should be crawled.
+type Scope func(u *url.URL) bool
+
+// Allows only URLs on the same hosts as the given URLs.
+func SameHost(urls ...string) Scope {
+	hosts := make(map[string]bool)
+	for _,s := range urls {
+		if u,e := url.Parse(s); e==nil { hosts[Normalize(u).Host] = true }
+	}
+	return func(u *url.URL) bool { return hosts[u.Host] }
+}
+
+// Allows only URLs, whose path starts with prefix.
+func PathPrefix(prefix string) Scope {
+	return func(u *url.URL) bool { return strings.HasPrefix(u.Path,prefix) }
+}
+
+// Allows only URLs, that match the regular expression re.
+func Regexp(re *regexp.Regexp) Scope {
+	return func(u *url.URL) bool { return re.MatchString(u.String()) }
+}
+
+// Allows only URLs, that are allowed by all scopes.
+func All(scopes ...Scope) Scope {
+	return func(u *url.URL) bool {
+		for _,s := range scopes {
+			if !s(u) { return false }
+		}
+		return true
+	}
+}
+
+// A fetched page.
+type Page struct{
+	URL      *url.URL
+	Depth    int
+
+	// The response. Its body has already been consumed.
+	Response *http.Response
+
+	// The parsed body (see webscrape.Parse).
+	Document webscrape.Document
+
+	// The parsed HTML document, or nil if the body is not HTML.
+	Node     *html.Node
+}
+
+type host struct{
+	mutex  sync.Mutex
+	once   sync.Once
+	robots *Robots
+	next   time.Time
+}
+
+/*
+ Crawler crawls the web, starting with a set of seed URLs. Only Client and
+ OnPage are required, all other fields have sensible defaults.
+ */
+type Crawler struct{
+	// The client. If it is an *http.Client, the Crawler follows the redirects
+	// itself, so that their targets are checked against the Scope and
+	// robots.txt and are crawled only once. Any other HttpClient should not
+	// follow redirects (see http.ErrUseLastResponse).
+	Client    webscrape.HttpClient
+
+	// The User-Agent header, also used to select the robots.txt rules.
+	UserAgent string
+
+	// Decides, which URLs are crawled. If nil, only URLs on the hosts of the
+	// seeds are crawled (so it must be set, when a crawl is resumed without
+	// seeds).
+	Scope     Scope
+
+	// The maximum link depth (the seeds have depth 0). 0 means unlimited.
+	MaxDepth  int
+
+	// The minimum delay between two requests to the same host. A larger
+	// Crawl-delay in robots.txt takes precedence.
+	Delay     time.Duration
+
+	// The number of concurrent fetches, 1 if 0.
+	Workers   int
+
+	// Don't fetch and respect robots.txt.
+	IgnoreRobots bool
+
+	// The Frontier. If nil, a MemFrontier is used.
+	Frontier  Frontier
+
+	// Called for every fetched page.
+	OnPage    func(p *Page)
+
+	// Called for every URL, that could not be fetched (may be nil).
+	OnError   func(u *url.URL,err error)
+
+	hmutex    sync.Mutex
+	hosts     map[string]*host
+	fetch     webscrape.HttpClient
+}
+
+// the client, that does not follow redirects.
+func (c *Crawler) client() webscrape.HttpClient {
+	hc,ok := c.Client.(*http.Client)
+	if !ok { return c.Client }
+	n := *hc
+	n.CheckRedirect = func(r *http.Request,via []*http.Request) error { return http.ErrUseLastResponse }
+	return &n
+}
+
+func (c *Crawler) host(u *url.URL) *host {
+	c.hmutex.Lock()
+	defer c.hmutex.Unlock()
+	if c.hosts==nil { c.hosts = make(map[string]*host) }
+	h,ok := c.hosts[u.Scheme+"://"+u.Host]
+	if !ok {
+		h = new(host)
+		c.hosts[u.Scheme+"://"+u.Host] = h
+	}
+	return h
+}
+
+func (c *Crawler) request(ctx context.Context,u string) (*http.Request,error) {
+	r,e := http.NewRequestWithContext(ctx,"GET",u,nil)
+	if e!=nil { return nil,e }
+	if c.UserAgent!="" { r.Header.Set("User-Agent",c.UserAgent) }
+	return r,nil
+}
+
+// the maximum size of a robots.txt file, the rest is ignored.
+const maxRobots = 500<<10
+
+func (c *Crawler) loadRobots(ctx context.Context,u *url.URL) *Robots {
+	r,e := c.request(ctx,u.Scheme+"://"+u.Host+"/robots.txt")
+	if e!=nil { return DisallowAll }
+	resp,e := c.Client.Do(r)
+	if e!=nil { return DisallowAll }
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode>=500:
+		return DisallowAll
+	case resp.StatusCode>=400:
+		return AllowAll
+	}
+	return ParseRobots(io.LimitReader(resp.Body,maxRobots),c.UserAgent)
+}
+
+// waits until a request to h may be sent. Reports false, if the URL is disallowed.
+func (c *Crawler) wait(ctx context.Context,h *host,u *url.URL) (bool,error) {
+	h.once.Do(func(){
+		h.robots = AllowAll
+		if !c.IgnoreRobots { h.robots = c.loadRobots(ctx,u) }
+	})
+	if !h.robots.Allowed(u.RequestURI()) { return false,nil }
+	delay := c.Delay
+	if h.robots.Delay>delay { delay = h.robots.Delay }
+	h.mutex.Lock()
+	t := time.Now()
+	if h.next.After(t) { t = h.next }
+	h.next = t.Add(delay)
+	h.mutex.Unlock()
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <- timer.C:
+		return true,nil
+	case <- ctx.Done():
+		return false,ctx.Err()
+	}
+}
+
+// Extracts the (absolute) links of an HTML document, honoring rel=nofollow.
+func Links(n *html.Node,base *url.URL) []*url.URL {
+	for _,m := range htmlscrape.FindAll(n,"meta") {
+		if htmlscrape.Attr(m,"name")=="robots" && strings.Contains(strings.ToLower(htmlscrape.Attr(m,"content")),"nofollow") {
+			return nil
+		}
+	}
+	var l []*url.URL
+	for _,a := range append(htmlscrape.FindAll(n,"a"),htmlscrape.FindAll(n,"area")...) {
+		href := strings.TrimSpace(htmlscrape.Attr(a,"href"))
+		if href=="" || strings.Contains(" "+htmlscrape.Attr(a,"rel")+" "," nofollow ") { continue }
+		u,e := base.Parse(href)
+		if e!=nil || (u.Scheme!="http" && u.Scheme!="https") { continue }
+		l = append(l,u)
+	}
+	return l
+}
+
+func isRedirect(code int) bool {
+	switch code {
+	case 301,302,303,307,308:
+		return true
+	}
+	return false
+}
+
+/*
+ fetches e and returns the links found and their depth. The target of a
+ redirect is returned as a link of the same depth. Reports false, if the
+ crawl was interrupted.
+ */
+func (c *Crawler) visit(ctx context.Context,e Entry) ([]*url.URL,int,bool) {
+	u,err := url.Parse(e.URL)
+	if err!=nil { return nil,0,true }
+	ok,err := c.wait(ctx,c.host(u),u)
+	if err!=nil { return nil,0,false }
+	if !ok { return nil,0,true }
+	r,err := c.request(ctx,e.URL)
+	if err==nil {
+		var resp *http.Response
+		resp,err = c.fetch.Do(r)
+		if err==nil {
+			defer resp.Body.Close()
+			if isRedirect(resp.StatusCode) && resp.Header.Get("Location")!="" {
+				t,err := u.Parse(resp.Header.Get("Location"))
+				if err==nil && (t.Scheme=="http" || t.Scheme=="https") { return []*url.URL{t},e.Depth,true }
+				return nil,0,true
+			}
+			if resp.StatusCode<200 || resp.StatusCode>299 {
+				err = &webscrape.StatusError{StatusCode:resp.StatusCode,Status:resp.Status}
+			} else {
+				return c.page(e,u,resp),e.Depth+1,true
+			}
+		}
+	}
+	if ctx.Err()!=nil { return nil,0,false }
+	if c.OnError!=nil { c.OnError(u,err) }
+	return nil,0,true
+}
+
+func (c *Crawler) page(e Entry,u *url.URL,resp *http.Response) []*url.URL {
+	if resp.Request!=nil { u = resp.Request.URL }
+	d,err := webscrape.Parse(resp.Body,resp.Header.Get("Content-Type"),u)
+	if err!=nil {
+		if c.OnError!=nil { c.OnError(u,err) }
+		return nil
+	}
+	p := &Page{URL:u,Depth:e.Depth,Response:resp,Document:d}
+	var links []*url.URL
+	if hd,ok := d.(webscrape.HTMLDocument); ok {
+		p.Node = hd.Node()
+		if c.MaxDepth==0 || e.Depth<c.MaxDepth { links = Links(p.Node,hd.Base()) }
+	}
+	c.OnPage(p)
+	return links
+}
+
+/*
+ Crawls the web starting with the seeds and returns, once the frontier is
+ empty or ctx is done. If a persisted Frontier is used, its entries are
+ crawled as well, so a crawl can be resumed by calling Run without seeds. A
+ Frontier with a Flush method (like FileFrontier) is flushed every few
+ seconds and before Run returns.
+ */
+func (c *Crawler) Run(ctx context.Context,seeds ...string) error {
+	fr := c.Frontier
+	if fr==nil { fr = NewMemFrontier() }
+	scope := c.Scope
+	if scope==nil { scope = SameHost(seeds...) }
+	for _,s := range seeds {
+		u,e := url.Parse(s)
+		if e!=nil { return e }
+		fr.Push(Entry{Normalize(u).String(),0})
+	}
+	workers := c.Workers
+	if workers<=0 { workers = 1 }
+	c.fetch = c.client()
+	fl,_ := fr.(flusher)
+	flushed := time.Now()
+
+	mutex := new(sync.Mutex)
+	cond := sync.NewCond(mutex)
+	active := 0
+	stop := context.AfterFunc(ctx,func(){
+		mutex.Lock()
+		cond.Broadcast()
+		mutex.Unlock()
+	})
+	defer stop()
+
+	wg := new(sync.WaitGroup)
+	wg.Add(workers)
+	for i := 0; i<workers; i++ {
+		go func(){
+			defer wg.Done()
+			for {
+				mutex.Lock()
+				var e Entry
+				var ok bool
+				for {
+					if ctx.Err()!=nil { break }
+					if e,ok = fr.Pop(); ok || active==0 { break }
+					cond.Wait()
+				}
+				if !ok {
+					cond.Broadcast()
+					mutex.Unlock()
+					return
+				}
+				active++
+				mutex.Unlock()
+
+				links,depth,done := c.visit(ctx,e)
+
+				mutex.Lock()
+				active--
+				if done {
+					for _,l := range links {
+						l = Normalize(l)
+						if scope(l) { fr.Push(Entry{l.String(),depth}) }
+					}
+					fr.Done(e)
+				}
+				if fl!=nil && time.Since(flushed)>=flushInterval {
+					fl.Flush()
+					flushed = time.Now()
+				}
+				cond.Broadcast()
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if fl!=nil {
+		if e := fl.Flush(); e!=nil && ctx.Err()==nil { return e }
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,197 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"golang.org/x/net/html"
+)
+
+func TestNormalize(t *testing.T) {
+	for in,want := range map[string]string{
+		"HTTP://Example.COM:80":"http://example.com/",
+		"https://example.com:443/a/./b/../c#frag":"https://example.com/a/c",
+		"http://user:pw@example.com:8080/?b=2&a=1":"http://example.com:8080/?a=1&b=2",
+	} {
+		u,_ := url.Parse(in)
+		if got := Normalize(u).String(); got!=want { t.Errorf("Normalize(%q) = %q, want %q",in,got,want) }
+	}
+}
+
+func TestScopes(t *testing.T) {
+	s := All(SameHost("http://Example.com/x"),PathPrefix("/docs/"),Regexp(regexp.MustCompile(`\.html$`)))
+	for in,want := range map[string]bool{
+		"http://example.com/docs/a.html":true,
+		"http://example.com/docs/a.pdf":false,
+		"http://example.com/blog/a.html":false,
+		"http://other.com/docs/a.html":false,
+	} {
+		u,_ := url.Parse(in)
+		if got := s(Normalize(u)); got!=want { t.Errorf("%s: got %v",in,got) }
+	}
+}
+
+func TestLinks(t *testing.T) {
+	base,_ := url.Parse("http://example.com/dir/")
+	n,_ := html.Parse(strings.NewReader(`<a href="a">1</a><a href="/b" rel="nofollow">2</a><a href="mailto:x@y">3</a><area href="http://other.com/c"><a href=" ">4</a>`))
+	var got []string
+	for _,u := range Links(n,base) { got = append(got,u.String()) }
+	if want := []string{"http://example.com/dir/a","http://other.com/c"}; !reflect.DeepEqual(got,want) { t.Errorf("got %v, want %v",got,want) }
+	n,_ = html.Parse(strings.NewReader(`<meta name="robots" content="noindex, NOFOLLOW"><a href="a">1</a>`))
+	if l := Links(n,base); l!=nil { t.Errorf("got %v despite nofollow",l) }
+}
+
+// a site, that counts the requests per path.
+type site struct{
+	mutex  sync.Mutex
+	hits   map[string]int
+	robots string
+	pages  map[string]string
+}
+
+func (s *site) ServeHTTP(w http.ResponseWriter,r *http.Request) {
+	s.mutex.Lock()
+	if s.hits==nil { s.hits = make(map[string]int) }
+	s.hits[r.URL.RequestURI()]++
+	s.mutex.Unlock()
+	if r.URL.Path=="/robots.txt" {
+		w.Write([]byte(s.robots))
+		return
+	}
+	p,ok := s.pages[r.URL.RequestURI()]
+	switch {
+	case !ok:
+		http.NotFound(w,r)
+	case strings.HasPrefix(p,"->"):
+		http.Redirect(w,r,p[2:],http.StatusFound)
+	default:
+		w.Header().Set("Content-Type","text/html")
+		w.Write([]byte(p))
+	}
+}
+
+func (s *site) visited() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var l []string
+	for p,n := range s.hits {
+		if n>1 { p = fmt.Sprintf("%s*%d",p,n) }
+		l = append(l,p)
+	}
+	sort.Strings(l)
+	return l
+}
+
+func crawl(t *testing.T,c *Crawler,seeds ...string) []string {
+	t.Helper()
+	var mutex sync.Mutex
+	var pages []string
+	c.OnPage = func(p *Page) {
+		mutex.Lock()
+		pages = append(pages,p.URL.RequestURI())
+		mutex.Unlock()
+	}
+	if c.Client==nil { c.Client = &http.Client{} }
+	if e := c.Run(context.Background(),seeds...); e!=nil { t.Fatal(e) }
+	sort.Strings(pages)
+	return pages
+}
+
+func TestRun(t *testing.T) {
+	s := &site{
+		robots:"User-agent: *\nDisallow: /private\n",
+		pages:map[string]string{
+			"/":`<a href="/a">a</a><a href="/a#top">a</a><a href="/b?y=1&amp;x=2">b</a><a href="/private/x">p</a><a href="/r">r</a><a href="/out">o</a><a href="/missing">m</a>`,
+			"/a":`->/b?x=2&y=1`,
+			"/b?x=2&y=1":`<a href="/">home</a>`,
+			"/r":`->/private/y`,
+			"/out":`->http://other.invalid/`,
+		},
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	var mutex sync.Mutex
+	var errs []string
+	c := &Crawler{Workers:3,OnError:func(u *url.URL,e error){
+		mutex.Lock()
+		errs = append(errs,u.RequestURI())
+		mutex.Unlock()
+	}}
+	pages := crawl(t,c,ts.URL)
+	if want := []string{"/","/b?x=2&y=1"}; !reflect.DeepEqual(pages,want) { t.Errorf("pages %v, want %v",pages,want) }
+	// the redirect targets are deduplicated and checked against robots.txt and the scope
+	if got,want := s.visited(),[]string{"/","/a","/b?x=2&y=1","/missing","/out","/r","/robots.txt"}; !reflect.DeepEqual(got,want) { t.Errorf("visited %v, want %v",got,want) }
+	if !reflect.DeepEqual(errs,[]string{"/missing"}) { t.Errorf("errors %v",errs) }
+}
+
+func TestMaxDepth(t *testing.T) {
+	s := &site{pages:map[string]string{
+		"/":`<a href="/1">1</a>`,
+		"/1":`<a href="/2">2</a>`,
+		"/2":`<a href="/3">3</a>`,
+	}}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	pages := crawl(t,&Crawler{MaxDepth:1},ts.URL)
+	if want := []string{"/","/1"}; !reflect.DeepEqual(pages,want) { t.Errorf("pages %v, want %v",pages,want) }
+}
+
+func TestRobotsLimit(t *testing.T) {
+	s := &site{
+		robots:"User-agent: *\nDisallow: /a\n"+strings.Repeat("#",maxRobots)+"\nDisallow: /b\n",
+		pages:map[string]string{"/":`<a href="/a">a</a><a href="/b">b</a>`,"/a":"a","/b":"b"},
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	pages := crawl(t,&Crawler{},ts.URL)
+	if want := []string{"/","/b"}; !reflect.DeepEqual(pages,want) { t.Errorf("pages %v, want %v",pages,want) }
+}
+
+func TestResume(t *testing.T) {
+	s := &site{pages:map[string]string{"/":`<a href="/a">a</a>`,"/a":`<a href="/b">b</a>`,"/b":"b"}}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	name := filepath.Join(t.TempDir(),"frontier")
+	f,e := OpenFrontier(name)
+	if e!=nil { t.Fatal(e) }
+	defer f.Close()
+	crawl(t,&Crawler{Frontier:f,MaxDepth:1},ts.URL)
+
+	// Run flushed the log, so it can be resumed without closing f.
+	f2,e := OpenFrontier(name)
+	if e!=nil { t.Fatal(e) }
+	defer f2.Close()
+	if f2.Len()!=0 { t.Errorf("%d entries left",f2.Len()) }
+	if f2.Push(Entry{Normalize(mustParse(ts.URL+"/a")).String(),1}) { t.Errorf("/a was not recorded") }
+}
+
+func mustParse(s string) *url.URL {
+	u,e := url.Parse(s)
+	if e!=nil { panic(e) }
+	return u
+}
@@ -0,0 +1,161 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package crawler
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A URL waiting to be crawled.
+type Entry struct{
+	URL   string // normalized, see Normalize
+	Depth int    // 0 for the seeds
+}
+
+/*
+ The Frontier keeps the URLs, that are waiting to be crawled, and the URLs,
+ that have been seen already. The Crawler serializes all calls, so
+ implementations need not to be safe for concurrent use.
+ */
+type Frontier interface{
+	// Adds e, unless its URL has been seen before. Reports, whether e was added.
+	Push(e Entry) bool
+
+	// Removes and returns the next Entry. Returns false if there is none.
+	Pop() (Entry,bool)
+
+	// Marks e (returned by Pop) as crawled.
+	Done(e Entry)
+}
+
+// A Frontier, that buffers its writes.
+type flusher interface{
+	Flush() error
+}
+
+// the interval, in which Run flushes the Frontier.
+const flushInterval = 5*time.Second
+
+// A Frontier, that is kept in memory (breadth first).
+type MemFrontier struct{
+	queue []Entry
+	seen  map[string]bool
+}
+
+func NewMemFrontier() *MemFrontier {
+	return &MemFrontier{seen:make(map[string]bool)}
+}
+
+func (f *MemFrontier) Push(e Entry) bool {
+	if f.seen[e.URL] { return false }
+	f.seen[e.URL] = true
+	f.queue = append(f.queue,e)
+	return true
+}
+
+func (f *MemFrontier) Pop() (Entry,bool) {
+	if len(f.queue)==0 { return Entry{},false }
+	e := f.queue[0]
+	f.queue[0] = Entry{}
+	f.queue = f.queue[1:]
+	return e,true
+}
+
+func (f *MemFrontier) Done(e Entry) {}
+
+// The number of Entries waiting.
+func (f *MemFrontier) Len() int { return len(f.queue) }
+
+/*
+ A Frontier, that is persisted to a file, so that an interrupted crawl can be
+ resumed. The file is a log of pushed ("+ depth url") and crawled ("- url")
+ entries. Entries, that had been popped but not crawled, are crawled again
+ after a restart.
+ */
+type FileFrontier struct{
+	*MemFrontier
+	f   *os.File
+	w   *bufio.Writer
+	err error
+}
+
+// Opens (or creates) the FileFrontier stored in the file name.
+func OpenFrontier(name string) (*FileFrontier,error) {
+	f,e := os.OpenFile(name,os.O_RDWR|os.O_CREATE|os.O_APPEND,0644)
+	if e!=nil { return nil,e }
+	ff := &FileFrontier{MemFrontier:NewMemFrontier(),f:f,w:bufio.NewWriter(f)}
+	done := make(map[string]bool)
+	var pushed []Entry
+	s := bufio.NewScanner(f)
+	s.Buffer(nil,1<<20)
+	for s.Scan() {
+		l := s.Text()
+		switch {
+		case strings.HasPrefix(l,"+ "):
+			p := strings.SplitN(l[2:]," ",2)
+			if len(p)!=2 { continue }
+			d,e := strconv.Atoi(p[0])
+			if e!=nil { continue }
+			pushed = append(pushed,Entry{p[1],d})
+		case strings.HasPrefix(l,"- "):
+			done[l[2:]] = true
+		}
+	}
+	if e = s.Err(); e!=nil {
+		f.Close()
+		return nil,e
+	}
+	for _,e := range pushed {
+		if done[e.URL] {
+			ff.seen[e.URL] = true
+		} else {
+			ff.MemFrontier.Push(e)
+		}
+	}
+	return ff,nil
+}
+
+func (f *FileFrontier) log(format string,args ...interface{}) {
+	if f.err==nil { _,f.err = fmt.Fprintf(f.w,format,args...) }
+}
+
+func (f *FileFrontier) Push(e Entry) bool {
+	if !f.MemFrontier.Push(e) { return false }
+	f.log("+ %d %s\n",e.Depth,e.URL)
+	return true
+}
+
+func (f *FileFrontier) Done(e Entry) {
+	f.log("- %s\n",e.URL)
+}
+
+// Writes the buffered log entries to the file.
+func (f *FileFrontier) Flush() error {
+	if f.err==nil { f.err = f.w.Flush() }
+	return f.err
+}
+
+func (f *FileFrontier) Close() error {
+	e := f.Flush()
+	if e2 := f.f.Close(); e==nil { e = e2 }
+	return e
+}
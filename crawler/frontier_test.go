@@ -0,0 +1,68 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package crawler
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func drain(f Frontier) []Entry {
+	var l []Entry
+	for {
+		e,ok := f.Pop()
+		if !ok { return l }
+		l = append(l,e)
+	}
+}
+
+func TestMemFrontier(t *testing.T) {
+	f := NewMemFrontier()
+	if !f.Push(Entry{"a",0}) || !f.Push(Entry{"b",1}) { t.Fatal("Push failed") }
+	if f.Push(Entry{"a",2}) { t.Errorf("a duplicate was pushed") }
+	if f.Len()!=2 { t.Errorf("Len = %d",f.Len()) }
+	if got,want := drain(f),[]Entry{{"a",0},{"b",1}}; !reflect.DeepEqual(got,want) { t.Errorf("got %v, want %v",got,want) }
+	if f.Push(Entry{"a",0}) { t.Errorf("a popped entry was pushed again") }
+}
+
+func TestFileFrontier(t *testing.T) {
+	name := filepath.Join(t.TempDir(),"frontier")
+	f,e := OpenFrontier(name)
+	if e!=nil { t.Fatal(e) }
+	f.Push(Entry{"a",0})
+	f.Push(Entry{"b",1})
+	f.Push(Entry{"c",1})
+	a,_ := f.Pop()
+	f.Done(a)
+	f.Pop() // b is popped, but not done
+	if e = f.Flush(); e!=nil { t.Fatal(e) }
+	// the entries are on disk before Close
+	f2,e := OpenFrontier(name)
+	if e!=nil { t.Fatal(e) }
+	if got,want := drain(f2),[]Entry{{"b",1},{"c",1}}; !reflect.DeepEqual(got,want) { t.Errorf("got %v, want %v",got,want) }
+	if f2.Push(Entry{"a",0}) { t.Errorf("a crawled entry was pushed again") }
+	f2.Close()
+	if e = f.Close(); e!=nil { t.Fatal(e) }
+
+	os.WriteFile(name,[]byte("garbage\n+ x y\n+ 2 d\n"),0644)
+	f3,e := OpenFrontier(name)
+	if e!=nil { t.Fatal(e) }
+	defer f3.Close()
+	if got,want := drain(f3),[]Entry{{"d",2}}; !reflect.DeepEqual(got,want) { t.Errorf("got %v, want %v",got,want) }
+}
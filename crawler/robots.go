@@ -0,0 +1,146 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package crawler
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type rule struct{
+	allow bool
+	path  string
+	re    *regexp.Regexp
+}
+
+func newRule(allow bool,path string) rule {
+	end := strings.HasSuffix(path,"$")
+	p := strings.TrimSuffix(path,"$")
+	parts := strings.Split(p,"*")
+	for i := range parts { parts[i] = regexp.QuoteMeta(parts[i]) }
+	re := "^"+strings.Join(parts,".*")
+	if end { re += "$" }
+	return rule{allow,path,regexp.MustCompile(re)}
+}
+
+/*
+ The rules of a robots.txt file, that apply to one user agent.
+ */
+type Robots struct{
+	rules []rule
+	// The Crawl-delay, or 0 if none is given.
+	Delay time.Duration
+}
+
+// Robots, that allow everything.
+var AllowAll = &Robots{}
+
+// Robots, that disallow everything.
+var DisallowAll = &Robots{rules:[]rule{newRule(false,"/")}}
+
+type group struct{
+	agents []string
+	rules  []rule
+	delay  time.Duration
+}
+
+/*
+ Parses a robots.txt file and returns the rules for the user agent agent.
+ Only its product token (eg. "scrapland" for "scrapland/1.0") is compared
+ with the User-agent lines, ignoring the case (RFC 9309). The rules of all
+ matching groups are merged; If no group matches agent, the groups for "*"
+ are used.
+ */
+func ParseRobots(r io.Reader,agent string) *Robots {
+	agent = productToken(agent)
+	var groups []*group
+	var cur *group
+	inAgents := false
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.IndexByte(line,'#'); i>=0 { line = line[:i] }
+		kv := strings.SplitN(line,":",2)
+		if len(kv)!=2 { continue }
+		k := strings.ToLower(strings.TrimSpace(kv[0]))
+		v := strings.TrimSpace(kv[1])
+		switch k {
+		case "user-agent":
+			if !inAgents {
+				cur = new(group)
+				groups = append(groups,cur)
+			}
+			cur.agents = append(cur.agents,productToken(v))
+			inAgents = true
+			continue
+		case "allow","disallow":
+			if cur!=nil && (v!="" || k=="allow") {
+				cur.rules = append(cur.rules,newRule(k=="allow",v))
+			}
+		case "crawl-delay":
+			if f,e := strconv.ParseFloat(v,64); cur!=nil && e==nil && f>0 {
+				cur.delay = time.Duration(f*float64(time.Second))
+			}
+		}
+		inAgents = false
+	}
+	if r := merge(groups,agent); r!=nil { return r }
+	if r := merge(groups,"*"); r!=nil { return r }
+	return AllowAll
+}
+
+// the lower case product token of a user agent, eg. "scrapland" for "Scrapland/1.0".
+func productToken(agent string) string {
+	agent = strings.ToLower(strings.TrimSpace(agent))
+	if i := strings.IndexAny(agent,"/ "); i>=0 { agent = agent[:i] }
+	return agent
+}
+
+// merges the groups for agent, nil if there are none. The longest Crawl-delay wins.
+func merge(groups []*group,agent string) *Robots {
+	var r *Robots
+	if agent=="" { return nil }
+	for _,g := range groups {
+		if !slices.Contains(g.agents,agent) { continue }
+		if r==nil { r = new(Robots) }
+		r.rules = append(r.rules,g.rules...)
+		r.Delay = max(r.Delay,g.delay)
+	}
+	return r
+}
+
+/*
+ Reports, whether the path (including the query) may be fetched. The most
+ specific (longest) matching rule wins, Allow wins ties.
+ */
+func (r *Robots) Allowed(path string) bool {
+	if path=="" { path = "/" }
+	allow,best := true,-1
+	for _,ru := range r.rules {
+		if !ru.re.MatchString(path) { continue }
+		n := len(ru.path)
+		if n>best || (n==best && ru.allow) {
+			allow,best = ru.allow,n
+		}
+	}
+	return allow
+}
@@ -0,0 +1,92 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package crawler
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRobots(t *testing.T) {
+	const txt = `
+# comment
+User-agent: scrapland
+User-agent: other
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+Crawl-delay: 1.5
+
+User-agent: *
+Disallow: /
+`
+	r := ParseRobots(strings.NewReader(txt),"Scrapland/1.0")
+	for path,want := range map[string]bool{
+		"/":true,
+		"/private":false,
+		"/private/x":false,
+		"/private/public/x":true,
+		"/a.pdf":false,
+		"/a.pdf?x=1":true,
+		"":true,
+	} {
+		if got := r.Allowed(path); got!=want { t.Errorf("Allowed(%q) = %v, want %v",path,got,want) }
+	}
+	if r.Delay!=1500*time.Millisecond { t.Errorf("Delay = %v",r.Delay) }
+
+	r = ParseRobots(strings.NewReader(txt),"unknownbot")
+	if r.Allowed("/") { t.Errorf("the * group was not used") }
+	r = ParseRobots(strings.NewReader("User-agent: x\nDisallow: /\n"),"y")
+	if r!=AllowAll { t.Errorf("no matching group should allow all") }
+	r = ParseRobots(strings.NewReader("User-agent: *\nDisallow:\n"),"y")
+	if !r.Allowed("/x") { t.Errorf("an empty Disallow should allow all") }
+	r = ParseRobots(strings.NewReader("User-agent: *\nDisallow: /a\nAllow: /a\n"),"y")
+	if !r.Allowed("/a") { t.Errorf("Allow should win ties") }
+	if DisallowAll.Allowed("/x") { t.Errorf("DisallowAll allowed /x") }
+}
+
+func TestRobotsGroups(t *testing.T) {
+	const txt = `
+User-agent: s
+Disallow: /s
+
+User-agent: SCRAPLAND
+Disallow: /a
+Crawl-delay: 1
+
+User-agent: *
+Disallow: /star
+
+User-agent: scrapland/2.0
+Disallow: /b
+Crawl-delay: 2
+
+User-agent: *
+Disallow: /star2
+`
+	r := ParseRobots(strings.NewReader(txt),"scrapland/1.0")
+	for path,want := range map[string]bool{"/s":true,"/a":false,"/b":false,"/star":true} {
+		if got := r.Allowed(path); got!=want { t.Errorf("Allowed(%q) = %v, want %v",path,got,want) }
+	}
+	if r.Delay!=2*time.Second { t.Errorf("Delay = %v",r.Delay) }
+
+	r = ParseRobots(strings.NewReader(txt),"scrap")
+	for path,want := range map[string]bool{"/a":true,"/star":false,"/star2":false} {
+		if got := r.Allowed(path); got!=want { t.Errorf("*: Allowed(%q) = %v, want %v",path,got,want) }
+	}
+}
@@ -43,7 +43,6 @@ func (this *Writer) Write(p []byte) (n int, err error) {
 	default:
 		return this.pr.Write(p)
 	}
-	return
 }
 func (this *Writer) Close() (err error) {
 	select {
@@ -0,0 +1,19 @@
+module github.com/maxymania/scrapland
+
+go 1.23
+
+require (
+	github.com/antchfx/xmlquery v1.4.4
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/jmespath/go-jmespath v0.4.0
+	github.com/pelletier/go-toml/v2 v2.2.3
+	golang.org/x/net v0.33.0
+	golang.org/x/text v0.21.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/antchfx/xpath v1.3.3 // indirect
+	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
+	golang.org/x/sys v0.28.0 // indirect
+)
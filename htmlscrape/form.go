@@ -0,0 +1,377 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package htmlscrape
+
+import (
+	"golang.org/x/net/html"
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrNoField  = errors.New("htmlscrape: no such form field")
+	ErrNoOption = errors.New("htmlscrape: no such option")
+)
+
+// An option of a select field.
+type Option struct{
+	Value    string
+	Label    string
+	Selected bool
+}
+
+// A form field (an input, select, textarea or button element).
+type Field struct{
+	Node     *html.Node
+	Name     string
+
+	// The input type (eg. "text", "hidden", "checkbox", "submit"), or "select"
+	// or "textarea".
+	Type     string
+
+	// The current value. For checkboxes and radios, the value, that is
+	// submitted when the field is checked.
+	Value    string
+
+	Checked  bool     // for checkboxes and radios
+	Multiple bool     // for selects
+	Options  []Option // for selects
+	Disabled bool
+}
+
+// a file set with Form.SetFile.
+type formFile struct{
+	name string
+	r    io.Reader
+}
+
+// A form and its fields, see ParseForm.
+type Form struct{
+	Node    *html.Node
+
+	// The action attribute, relative to the base URL of the document.
+	Action  string
+	// "GET" or "POST".
+	Method  string
+	// The encoding, "application/x-www-form-urlencoded", "multipart/form-data"
+	// or "text/plain".
+	Enctype string
+
+	// The fields in document order.
+	Fields  []*Field
+
+	files   map[string]formFile
+	clicked *Field
+}
+
+// reports, whether the field n is disabled itself or by an enclosing fieldset.
+func disabled(n,form *html.Node) bool {
+	if HasAttr(n,"disabled") { return true }
+	for n = n.Parent; n!=nil && n!=form; n = n.Parent {
+		if n.Type==html.ElementNode && n.Data=="fieldset" && HasAttr(n,"disabled") { return true }
+	}
+	return false
+}
+
+func parseField(n *html.Node) *Field {
+	f := &Field{Node:n,Name:Attr(n,"name")}
+	switch n.Data {
+	case "input":
+		f.Type = strings.ToLower(Attr(n,"type"))
+		if f.Type=="" { f.Type = "text" }
+		f.Value = Attr(n,"value")
+		switch f.Type {
+		case "checkbox","radio":
+			f.Checked = HasAttr(n,"checked")
+			if !HasAttr(n,"value") { f.Value = "on" }
+		}
+	case "button":
+		f.Type = strings.ToLower(Attr(n,"type"))
+		if f.Type=="" { f.Type = "submit" }
+		f.Value = Attr(n,"value")
+	case "textarea":
+		f.Type = "textarea"
+		f.Value = strings.TrimPrefix(ExtractText(n),"\n")
+	case "select":
+		f.Type = "select"
+		f.Multiple = HasAttr(n,"multiple")
+		selected := false
+		for _,o := range FindAll(n,"option") {
+			opt := Option{Value:Attr(o,"value"),Label:whiteSpace.ReplaceAllString(strings.TrimSpace(ExtractText(o))," ")}
+			if !HasAttr(o,"value") { opt.Value = opt.Label }
+			opt.Selected = HasAttr(o,"selected") && (f.Multiple || !selected)
+			selected = selected || opt.Selected
+			f.Options = append(f.Options,opt)
+		}
+		// a single select without a selected option submits the first one.
+		if !f.Multiple && !selected && len(f.Options)>0 { f.Options[0].Selected = true }
+	}
+	return f
+}
+
+/*
+ Models the form element n: Its action, method, encoding and fields with
+ their default values. Returns nil, if n is not a form.
+ */
+func ParseForm(n *html.Node) *Form {
+	if !Match(n,"form") { return nil }
+	f := &Form{Node:n,Action:Attr(n,"action"),Method:"GET",Enctype:"application/x-www-form-urlencoded"}
+	if strings.EqualFold(Attr(n,"method"),"post") { f.Method = "POST" }
+	switch et := strings.ToLower(Attr(n,"enctype")); et {
+	case "multipart/form-data","text/plain":
+		f.Enctype = et
+	}
+	for _,c := range collect(n.FirstChild,n.LastChild,nil) {
+		if c.Type!=html.ElementNode { continue }
+		switch c.Data {
+		case "input","button","select","textarea":
+			fd := parseField(c)
+			fd.Disabled = disabled(c,n)
+			f.Fields = append(f.Fields,fd)
+		}
+	}
+	return f
+}
+
+// Returns all forms within n.
+func Forms(n *html.Node) []*Form {
+	var l []*Form
+	for _,c := range FindAll(n,"form") { l = append(l,ParseForm(c)) }
+	return l
+}
+
+// Returns the first field named name, or nil.
+func (f *Form) Field(name string) *Field {
+	for _,fd := range f.Fields {
+		if fd.Name==name { return fd }
+	}
+	return nil
+}
+
+/*
+ Sets the value of the field name. For a checkbox or radio, the field with the
+ given value is checked (and the other radios of the group are unchecked).
+ For a select, the option with the value (or label) is selected; A multiple
+ select keeps its other selected options.
+ */
+func (f *Form) Set(name,value string) error {
+	found := false
+	for _,fd := range f.Fields {
+		if fd.Name!=name { continue }
+		switch fd.Type {
+		case "checkbox":
+			if fd.Value==value {
+				fd.Checked = true
+				return nil
+			}
+			found = true
+		case "radio":
+			found = true
+		case "select":
+			i := -1
+			for j,o := range fd.Options {
+				if o.Value==value || (i<0 && o.Label==value) { i = j }
+			}
+			if i<0 { return ErrNoOption }
+			for j := range fd.Options {
+				if j==i {
+					fd.Options[j].Selected = true
+				} else if !fd.Multiple {
+					fd.Options[j].Selected = false
+				}
+			}
+			return nil
+		case "submit","image","reset","button","file":
+		default:
+			fd.Value = value
+			return nil
+		}
+	}
+	if !found { return ErrNoField }
+	// the group is left unchanged, if no radio has the value.
+	checked := false
+	for _,fd := range f.Fields {
+		checked = checked || (fd.Name==name && fd.Type=="radio" && fd.Value==value)
+	}
+	if !checked { return ErrNoOption }
+	for _,fd := range f.Fields {
+		if fd.Name==name && fd.Type=="radio" { fd.Checked = fd.Value==value }
+	}
+	return nil
+}
+
+// Unchecks the checkbox name with the given value, or deselects the option.
+func (f *Form) Unset(name,value string) error {
+	for _,fd := range f.Fields {
+		if fd.Name!=name { continue }
+		switch fd.Type {
+		case "checkbox","radio":
+			if fd.Value==value {
+				fd.Checked = false
+				return nil
+			}
+		case "select":
+			for j,o := range fd.Options {
+				if o.Value==value {
+					fd.Options[j].Selected = false
+					return nil
+				}
+			}
+		}
+	}
+	return ErrNoField
+}
+
+// Adds a hidden field, eg. for a token, that is not part of the form.
+func (f *Form) Add(name,value string) {
+	f.Fields = append(f.Fields,&Field{Name:name,Type:"hidden",Value:value})
+}
+
+// Sets the file input name. It is only submitted with multipart encoding; r
+// is read by Request.
+func (f *Form) SetFile(name,filename string,r io.Reader) error {
+	fd := f.Field(name)
+	if fd==nil || fd.Type!="file" { return ErrNoField }
+	if f.files==nil { f.files = make(map[string]formFile) }
+	f.files[name] = formFile{filename,r}
+	return nil
+}
+
+/*
+ Selects the submit button name as the submitter: Its name and value are
+ submitted and its formaction, formmethod and formenctype attributes take
+ precedence over the ones of the form.
+ */
+func (f *Form) Click(name string) error {
+	for _,fd := range f.Fields {
+		if fd.Name==name && (fd.Type=="submit" || fd.Type=="image") && !fd.Disabled {
+			f.clicked = fd
+			return nil
+		}
+	}
+	return ErrNoField
+}
+
+// the name/value pairs, that would be submitted, in document order. Files
+// are represented by their field.
+func (f *Form) pairs() (l []pair) {
+	for _,fd := range f.Fields {
+		if fd.Name=="" || fd.Disabled { continue }
+		switch fd.Type {
+		case "submit","image":
+			if fd!=f.clicked { continue }
+			if fd.Type=="image" {
+				l = append(l,pair{fd.Name+".x","0",nil},pair{fd.Name+".y","0",nil})
+				continue
+			}
+		case "reset","button":
+			continue
+		case "file":
+			l = append(l,pair{fd.Name,"",fd})
+			continue
+		case "checkbox","radio":
+			if !fd.Checked { continue }
+		case "select":
+			for _,o := range fd.Options {
+				if o.Selected { l = append(l,pair{fd.Name,o.Value,nil}) }
+			}
+			continue
+		}
+		l = append(l,pair{fd.Name,fd.Value,nil})
+	}
+	return
+}
+
+type pair struct{
+	name,value string
+	file       *Field
+}
+
+// Returns the values, that would be submitted (without files).
+func (f *Form) Values() url.Values {
+	v := make(url.Values)
+	for _,p := range f.pairs() {
+		if p.file==nil { v.Add(p.name,p.value) }
+	}
+	return v
+}
+
+/*
+ Creates the request, that submits the form. The action is resolved against
+ base (the base URL of the document, eg. the one returned by BaseURL).
+ */
+func (f *Form) Request(base *url.URL) (*http.Request,error) {
+	action,method,enctype := f.Action,f.Method,f.Enctype
+	if c := f.clicked; c!=nil && c.Node!=nil {
+		if HasAttr(c.Node,"formaction") { action = Attr(c.Node,"formaction") }
+		if m := Attr(c.Node,"formmethod"); m!="" { method = strings.ToUpper(m) }
+		if et := Attr(c.Node,"formenctype"); et!="" { enctype = strings.ToLower(et) }
+	}
+	u,e := base.Parse(action)
+	if e!=nil { return nil,e }
+	u.Fragment = ""
+	ps := f.pairs()
+	if method!="POST" {
+		u.RawQuery = urlencode(ps)
+		return http.NewRequest("GET",u.String(),nil)
+	}
+	var body bytes.Buffer
+	switch enctype {
+	case "multipart/form-data":
+		mw := multipart.NewWriter(&body)
+		for _,p := range ps {
+			if p.file==nil {
+				e = mw.WriteField(p.name,p.value)
+			} else {
+				ff := f.files[p.name]
+				var w io.Writer
+				if w,e = mw.CreateFormFile(p.name,ff.name); e==nil && ff.r!=nil { _,e = io.Copy(w,ff.r) }
+			}
+			if e!=nil { return nil,e }
+		}
+		if e = mw.Close(); e!=nil { return nil,e }
+		enctype = mw.FormDataContentType()
+	case "text/plain":
+		for _,p := range ps {
+			if p.file==nil { body.WriteString(p.name+"="+p.value+"\r\n") }
+		}
+	default:
+		enctype = "application/x-www-form-urlencoded"
+		body.WriteString(urlencode(ps))
+	}
+	r,e := http.NewRequest("POST",u.String(),bytes.NewReader(body.Bytes()))
+	if e!=nil { return nil,e }
+	r.Header.Set("Content-Type",enctype)
+	return r,nil
+}
+
+// like url.Values.Encode, but keeps the order. Files have an empty value.
+func urlencode(ps []pair) string {
+	var b strings.Builder
+	for _,p := range ps {
+		if b.Len()>0 { b.WriteByte('&') }
+		b.WriteString(url.QueryEscape(p.name))
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(p.value))
+	}
+	return b.String()
+}
@@ -0,0 +1,126 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package htmlscrape
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testForm = `<form action="/search#x" method="post">
+<input name="q" value="go">
+<input type="hidden" name="token" value="t1">
+<input type="checkbox" name="opt" value="a" checked>
+<input type="checkbox" name="opt" value="b">
+<input type="checkbox" name="flag" checked>
+<input type="radio" name="r" value="1" checked>
+<input type="radio" name="r" value="2">
+<select name="s"><option>One</option><option value="2">Two</option></select>
+<select name="m" multiple><option value="x" selected>X</option><option value="y">Y</option></select>
+<textarea name="t">
+text</textarea>
+<input name="off" value="v" disabled>
+<fieldset disabled><input name="fs" value="v"></fieldset>
+<input type="file" name="f">
+<input type="reset" name="reset">
+<button name="go" value="1">Go</button>
+<input type="submit" name="alt" value="Alt" formaction="/alt" formmethod="get">
+</form>`
+
+func TestParseForm(t *testing.T) {
+	f := ParseForm(LurkFor(doc(t,testForm),"form"))
+	if f==nil { t.Fatal("no form") }
+	if f.Method!="POST" || f.Action!="/search#x" || f.Enctype!="application/x-www-form-urlencoded" { t.Errorf("got %s %s %s",f.Method,f.Action,f.Enctype) }
+	want := "flag=on&m=x&opt=a&q=go&r=1&s=One&t=text&token=t1"
+	if got := f.Values().Encode(); got!=want { t.Errorf("got %s, want %s",got,want) }
+	if fd := f.Field("off"); fd==nil || !fd.Disabled { t.Errorf("off is not disabled") }
+	if fd := f.Field("fs"); fd==nil || !fd.Disabled { t.Errorf("the fieldset does not disable fs") }
+	if ParseForm(LurkFor(doc(t,testForm),"input"))!=nil { t.Errorf("an input was parsed as form") }
+	if n := len(Forms(doc(t,testForm+testForm))); n!=2 { t.Errorf("%d forms",n) }
+}
+
+func TestFormSet(t *testing.T) {
+	f := ParseForm(LurkFor(doc(t,testForm),"form"))
+	tests := []struct{
+		name,value string
+		err        error
+	}{
+		{"q","rust",nil},
+		{"opt","b",nil},
+		{"r","2",nil},
+		{"s","2",nil},
+		{"m","y",nil},
+		{"t","new",nil},
+		{"r","3",ErrNoOption},
+		{"s","Three",ErrNoOption},
+		{"missing","x",ErrNoField},
+	}
+	for _,tt := range tests {
+		if e := f.Set(tt.name,tt.value); e!=tt.err { t.Errorf("Set(%s,%s) = %v, want %v",tt.name,tt.value,e,tt.err) }
+	}
+	if e := f.Unset("opt","a"); e!=nil { t.Error(e) }
+	if e := f.Unset("m","x"); e!=nil { t.Error(e) }
+	if e := f.Unset("q","x"); e!=ErrNoField { t.Errorf("got %v",e) }
+	f.Add("extra","1")
+	if e := f.Click("go"); e!=nil { t.Error(e) }
+	want,_ := url.ParseQuery("flag=on&go=1&m=y&opt=b&q=rust&r=2&s=2&t=new&token=t1&extra=1")
+	if got := f.Values(); !reflect.DeepEqual(got,want) { t.Errorf("got %v, want %v",got,want) }
+	if e := f.Click("reset"); e!=ErrNoField { t.Errorf("clicked a reset button: %v",e) }
+}
+
+func TestFormRequest(t *testing.T) {
+	base := mustURL(t,"http://example.com/dir/page")
+	f := ParseForm(LurkFor(doc(t,testForm),"form"))
+	r,e := f.Request(base)
+	if e!=nil { t.Fatal(e) }
+	b,_ := io.ReadAll(r.Body)
+	if r.Method!="POST" || r.URL.String()!="http://example.com/search" || r.Header.Get("Content-Type")!="application/x-www-form-urlencoded" { t.Errorf("got %s %s %s",r.Method,r.URL,r.Header.Get("Content-Type")) }
+	// the file name of a file input is sent, when it is not multipart encoded
+	if want := "q=go&token=t1&opt=a&flag=on&r=1&s=One&m=x&t=text&f="; string(b)!=want { t.Errorf("got %s, want %s",b,want) }
+	if r.GetBody==nil { t.Errorf("the request cannot be retried") }
+
+	// the submit button overrides the action and method
+	f.Click("alt")
+	r,e = f.Request(base)
+	if e!=nil { t.Fatal(e) }
+	if r.Method!="GET" || r.URL.Path!="/alt" || r.URL.Query().Get("alt")!="Alt" { t.Errorf("got %s %s",r.Method,r.URL) }
+
+	f = ParseForm(LurkFor(doc(t,`<form method=post enctype="multipart/form-data"><input name="a" value="1"><input type="file" name="f"></form>`),"form"))
+	if e = f.SetFile("f","a.txt",strings.NewReader("content")); e!=nil { t.Fatal(e) }
+	if e = f.SetFile("a","a.txt",nil); e!=ErrNoField { t.Errorf("got %v",e) }
+	r,e = f.Request(base)
+	if e!=nil { t.Fatal(e) }
+	_,params,_ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	mr := multipart.NewReader(r.Body,params["boundary"])
+	got := make(map[string]string)
+	for {
+		p,e := mr.NextPart()
+		if e!=nil { break }
+		b,_ := io.ReadAll(p)
+		got[p.FormName()+":"+p.FileName()] = string(b)
+	}
+	if want := map[string]string{"a:":"1","f:a.txt":"content"}; !reflect.DeepEqual(got,want) { t.Errorf("got %v, want %v",got,want) }
+
+	f = ParseForm(LurkFor(doc(t,`<form action="?x=1" method=get><input name="a" value="b c"></form>`),"form"))
+	r,_ = f.Request(base)
+	if r.URL.String()!="http://example.com/dir/page?a=b+c" { t.Errorf("got %s",r.URL) }
+}
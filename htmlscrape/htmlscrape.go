@@ -46,18 +46,36 @@ func ReplaceHref(f func(string)string) Transf{
 	}
 }
 
-func walk(begin, end *html.Node,fn Transf) {
-	if begin==nil { return }
+func collect(begin, end *html.Node,l []*html.Node) []*html.Node {
+	if begin==nil { return l }
 	for {
-		fn(begin)
-		walk(begin.FirstChild,begin.LastChild,fn)
-		if end==begin { return }
+		l = append(l,begin)
+		l = collect(begin.FirstChild,begin.LastChild,l)
+		if end==begin { return l }
 		begin = begin.NextSibling
 	}
 }
 
+// reports, whether n is root or a descendant of root.
+func attached(n, root *html.Node) bool {
+	for ; n!=nil; n = n.Parent {
+		if n==root { return true }
+	}
+	return false
+}
+
+/*
+ Calls fn for n and all its descendants in document order.
+
+ It is safe for fn to modify the tree: The nodes are collected before fn is
+ called, so fn may remove, replace, unwrap or wrap the node it is called with.
+ Nodes, that are inserted by fn are not visited, nodes that have been removed
+ from n's subtree (eg. the children of a removed node) are skipped.
+ */
 func Walk(n *html.Node,fn Transf){
-	walk(n,n,fn)
+	for _,c := range collect(n,n,nil) {
+		if c==n || attached(c,n) { fn(c) }
+	}
 }
 
 func find(begin, end *html.Node) (t *html.Node,b *html.Node){
@@ -79,7 +97,6 @@ func find(begin, end *html.Node) (t *html.Node,b *html.Node){
 		if end==begin { return }
 		begin = begin.NextSibling
 	}
-	return
 }
 // finds title and body in an html doc
 func FindTB(n *html.Node) (t *html.Node,b *html.Node){ return find(n,n) }
@@ -96,7 +113,6 @@ func Render(w io.Writer,n *html.Node) error{
 		if end==begin { return nil }
 		begin = begin.NextSibling
 	}
-	return nil
 }
 
 func findAttr(h *html.Node,k string) string {
@@ -106,21 +122,48 @@ func findAttr(h *html.Node,k string) string {
 	return ""
 }
 
+// Returns the value of the attribute k of h, or "" if h has no such attribute.
+func Attr(h *html.Node,k string) string { return findAttr(h,k) }
+
+// Reports, whether h has the attribute k (eg. a boolean attribute like "checked").
+func HasAttr(h *html.Node,k string) bool {
+	for _,a := range h.Attr {
+		if a.Key==k { return true }
+	}
+	return false
+}
+
+func match(h *html.Node,sel string) bool{
+	switch sel[0]{
+	case '.':
+		for _,cls := range whiteSpace.Split(findAttr(h,"class"),-1) {
+			if cls==sel[1:] { return true }
+		}
+	case '#':
+		return findAttr(h,"id")==sel[1:]
+	default:
+		return h.Data==sel
+	}
+	return false
+}
+
+/*
+ Reports, whether h is an element, that matches the selector sel. The selector
+ has the same syntax as in LurkFor: ".class", "#id" or "tag". The selectors ""
+ and "*" match every element.
+ */
+func Match(h *html.Node,sel string) bool{
+	if h==nil || h.Type!=html.ElementNode { return false }
+	if sel=="" || sel=="*" { return true }
+	return match(h,sel)
+}
+
 func lurkFor(begin, end *html.Node,sel string) *html.Node{
 	if begin==nil { return nil }
 	for {
 		switch begin.Type {
 		case html.ElementNode:
-			switch sel[0]{
-			case '.':
-				for _,cls := range whiteSpace.Split(findAttr(begin,"class"),-1) {
-					if cls==sel[1:] { return begin }
-				}
-			case '#':
-				if findAttr(begin,"id")==sel[1:] { return begin }
-			default:
-				if begin.Data==sel { return begin }
-			}
+			if match(begin,sel) { return begin }
 			fallthrough
 		case html.DocumentNode:
 			res := lurkFor(begin.FirstChild,begin.LastChild,sel)
@@ -129,7 +172,6 @@ func lurkFor(begin, end *html.Node,sel string) *html.Node{
 		if end==begin { return nil }
 		begin = begin.NextSibling
 	}
-	return nil
 }
 // finds title and body in an html doc
 func LurkFor(n *html.Node,sel string) *html.Node{
@@ -155,7 +197,6 @@ func extractText(begin, end *html.Node,d io.Writer) {
 		if end==begin { return }
 		begin = begin.NextSibling
 	}
-	return
 }
 func ExtractText(h *html.Node) string {
 	w := &bytes.Buffer{}
@@ -0,0 +1,359 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package htmlscrape
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var mdSpecial = regexp.MustCompile("[\\\\`*_\\[\\]<>]")
+var backticks = regexp.MustCompile("`+")
+
+// text at the start of a line, that would begin a heading, list, quote or fence.
+var mdBlockStart = regexp.MustCompile(`^(?:[#+=~-]|\d{1,9}[.)])`)
+
+/*
+ A MarkdownRule renders the element n into w. If it returns false, the
+ default rendering is used instead.
+ */
+type MarkdownRule func(w *MarkdownWriter,n *html.Node) bool
+
+/*
+ Markdown converts HTML into CommonMark (with GFM tables and strikethrough).
+ The zero value is ready to use.
+ */
+type Markdown struct{
+	// Custom rules, keyed by tag name (eg. "video").
+	Rules  map[string]MarkdownRule
+
+	// The bullet for unordered lists, "-" if empty.
+	Bullet string
+}
+
+var defaultMarkdown = new(Markdown)
+
+// MarkdownWriter is passed to MarkdownRules.
+type MarkdownWriter struct{
+	t     *textWriter
+	m     *Markdown
+	cell  bool
+}
+
+func (m *Markdown) writer(w io.Writer,blocks bool) *MarkdownWriter {
+	return &MarkdownWriter{t:&textWriter{w:w,o:&TextOptions{SkipScript:true,Blocks:blocks,Collapse:true}},m:m}
+}
+
+// reports, whether the next text starts a line.
+func (w *MarkdownWriter) lineStart() bool {
+	t := w.t
+	return !t.started || t.bol || t.brk>0 || t.nl>0
+}
+
+/*
+ Writes text, escaping the characters that have a meaning in Markdown, as
+ well as the block markers (eg. "#", "-" or "1.") at the start of a line.
+ */
+func (w *MarkdownWriter) Text(s string) {
+	if w.t.pre>0 {
+		w.t.text(s)
+		return
+	}
+	s = mdSpecial.ReplaceAllString(s,`\$0`)
+	if w.cell { s = strings.Replace(s,"|",`\|`,-1) }
+	if w.lineStart() {
+		lead := len(s)-len(strings.TrimLeft(s," \t\r\n"))
+		if l := mdBlockStart.FindStringIndex(s[lead:]); l!=nil {
+			i := lead+l[1]-1
+			s = s[:i]+`\`+s[i:]
+		}
+	}
+	w.t.text(s)
+}
+
+// Writes Markdown verbatim (whitespace is collapsed).
+func (w *MarkdownWriter) Raw(s string) {
+	w.t.text(s)
+}
+
+// Requests n line breaks before the next output (2 yields a blank line).
+func (w *MarkdownWriter) Block(n int) {
+	w.t.block(n)
+}
+
+// Renders the element n as a block, whose lines are prefixed with prefix (eg. "> ").
+func (w *MarkdownWriter) Indent(prefix string,fn func()) {
+	w.t.block(2)
+	w.t.breaks()
+	old := w.t.prefix
+	w.t.prefix += prefix
+	fn()
+	w.t.prefix = old
+	w.t.block(2)
+}
+
+// Renders the children of n.
+func (w *MarkdownWriter) Children(n *html.Node) {
+	for c := n.FirstChild; c!=nil; c = c.NextSibling { w.Node(c) }
+}
+
+// Renders the children of n into a single line and returns it.
+func (w *MarkdownWriter) Inline(n *html.Node) string {
+	buf := &bytes.Buffer{}
+	s := w.m.writer(buf,false)
+	s.cell = w.cell
+	s.Children(n)
+	return strings.TrimSpace(buf.String())
+}
+
+// wraps the inline content of n into mark, keeping surrounding whitespace outside.
+func (w *MarkdownWriter) wrap(n *html.Node,open,close string) {
+	s := w.Inline(n)
+	if s=="" { return }
+	lead := n.FirstChild!=nil && n.FirstChild.Type==html.TextNode &&
+		strings.TrimLeft(n.FirstChild.Data," \t\r\n")!=n.FirstChild.Data
+	if lead { w.t.space = w.t.started }
+	w.t.text(open+s+close)
+	if n.LastChild!=nil && n.LastChild.Type==html.TextNode &&
+		strings.TrimRight(n.LastChild.Data," \t\r\n")!=n.LastChild.Data {
+		w.t.space = true
+	}
+}
+
+// returns a run of at least min backticks, that is longer than every run within s.
+func fence(s string,min int) string {
+	n := min
+	for _,b := range backticks.FindAllString(s,-1) {
+		if len(b)>=n { n = len(b)+1 }
+	}
+	return strings.Repeat("`",n)
+}
+
+func (w *MarkdownWriter) list(n *html.Node) {
+	i := 1
+	if v,e := strconv.Atoi(findAttr(n,"start")); e==nil { i = v }
+	bullet := w.m.Bullet
+	if bullet=="" { bullet = "-" }
+	sep := 2
+	if w.t.lists>0 { sep = 1 }
+	w.t.block(sep)
+	w.t.lists++
+	old := w.t.prefix
+	for c := n.FirstChild; c!=nil; c = c.NextSibling {
+		if c.Type!=html.ElementNode || c.DataAtom!=atom.Li {
+			if c.Type==html.ElementNode { w.Node(c) }
+			continue
+		}
+		marker := bullet+" "
+		if n.DataAtom==atom.Ol {
+			marker = strconv.Itoa(i)+". "
+			i++
+		}
+		w.t.block(1)
+		w.t.flush()
+		w.t.raw(marker)
+		// absorb the line breaks of a leading block within the item.
+		w.t.brk = 2
+		w.t.prefix = old+strings.Repeat(" ",len(marker))
+		w.Children(c)
+		w.t.prefix = old
+		w.t.block(1)
+	}
+	w.t.lists--
+	w.t.block(sep)
+}
+
+func (w *MarkdownWriter) table(n *html.Node) {
+	rows := tableRows(n,nil)
+	if len(rows)==0 { return }
+	cols := 0
+	for _,r := range rows {
+		if len(r)>cols { cols = len(r) }
+	}
+	if cols==0 { return }
+	w.t.block(2)
+	w.cell = true
+	for i,r := range rows {
+		cells := make([]string,cols)
+		for j,c := range r { cells[j] = w.Inline(c) }
+		w.t.block(1)
+		w.t.flush()
+		w.t.raw("| "+strings.Join(cells," | ")+" |")
+		if i==0 {
+			w.t.raw("\n"+w.t.prefix+"|"+strings.Repeat(" --- |",cols))
+		}
+	}
+	w.cell = false
+	w.t.block(2)
+}
+
+func (w *MarkdownWriter) code(n *html.Node) {
+	code := n
+	if c := LurkFor(n,"code"); c!=nil { code = c }
+	lang := ""
+	for _,cls := range whiteSpace.Split(findAttr(code,"class"),-1) {
+		if strings.HasPrefix(cls,"language-") { lang = cls[9:] }
+		if strings.HasPrefix(cls,"lang-") { lang = cls[5:] }
+	}
+	s := strings.TrimSuffix(ExtractText(n),"\n")
+	f := fence(s,3)
+	w.t.block(2)
+	w.t.flush()
+	w.t.raw(f+lang+"\n"+w.t.prefix)
+	w.t.pre++
+	w.t.text(s)
+	w.t.pre--
+	w.t.raw("\n"+w.t.prefix+f)
+	w.t.block(2)
+}
+
+// the destination of a link or image, whose URL must be safe (see Sanitize).
+func mdLink(href,title string) string {
+	href = strings.Replace(strings.Replace(href," ","%20",-1),")","%29",-1)
+	if title!="" { return "("+href+` "`+strings.Replace(title,`"`,`\"`,-1)+`")` }
+	return "("+href+")"
+}
+
+// Renders the node n.
+func (w *MarkdownWriter) Node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.Text(n.Data)
+		return
+	case html.DocumentNode:
+		w.Children(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+	if r,ok := w.m.Rules[n.Data]; ok && r(w,n) { return }
+	switch n.DataAtom {
+	case atom.Script,atom.Style,atom.Template,atom.Noscript,atom.Head:
+		return
+	case atom.H1,atom.H2,atom.H3,atom.H4,atom.H5,atom.H6:
+		if !w.t.o.Blocks { break }
+		w.t.block(2)
+		w.t.flush()
+		w.t.raw(strings.Repeat("#",int(n.Data[1]-'0'))+" ")
+		w.t.text(w.Inline(n))
+		w.t.block(2)
+		return
+	case atom.Em,atom.I,atom.Cite,atom.Var,atom.Dfn:
+		w.wrap(n,"*","*")
+		return
+	case atom.Strong,atom.B:
+		w.wrap(n,"**","**")
+		return
+	case atom.Del,atom.S,atom.Strike:
+		w.wrap(n,"~~","~~")
+		return
+	case atom.Code,atom.Kbd,atom.Samp,atom.Tt:
+		s := whiteSpace.ReplaceAllString(ExtractText(n)," ")
+		if s=="" { return }
+		f := fence(s,1)
+		if strings.HasPrefix(s,"`") || strings.HasSuffix(s,"`") { s = " "+s+" " }
+		if w.cell { s = strings.Replace(s,"|",`\|`,-1) }
+		w.t.text(f+s+f)
+		return
+	case atom.A:
+		href := findAttr(n,"href")
+		s := w.Inline(n)
+		if href=="" || !safeURL(href,false) {
+			w.t.text(s)
+		} else if s==href && strings.Contains(href,"://") {
+			w.t.text("<"+href+">")
+		} else {
+			w.t.text("["+s+"]"+mdLink(href,findAttr(n,"title")))
+		}
+		return
+	case atom.Img:
+		src := findAttr(n,"src")
+		if src=="" || !safeURL(src,true) { return }
+		w.t.text("!["+mdSpecial.ReplaceAllString(findAttr(n,"alt"),`\$0`)+"]"+mdLink(src,findAttr(n,"title")))
+		return
+	case atom.Br:
+		if w.t.o.Blocks {
+			w.t.flush()
+			w.t.raw("\\\n")
+			w.t.bol = true
+			w.t.brk = 1
+		} else {
+			w.t.space = true
+		}
+		return
+	case atom.Hr:
+		if !w.t.o.Blocks { break }
+		w.t.block(2)
+		w.t.flush()
+		w.t.raw("---")
+		w.t.block(2)
+		return
+	case atom.Pre:
+		if !w.t.o.Blocks { break }
+		w.code(n)
+		return
+	case atom.Blockquote:
+		if !w.t.o.Blocks { break }
+		w.Indent("> ",func(){ w.Children(n) })
+		return
+	case atom.Ul,atom.Ol:
+		if !w.t.o.Blocks { break }
+		w.list(n)
+		return
+	case atom.Table:
+		if !w.t.o.Blocks { break }
+		w.table(n)
+		return
+	}
+	sep,ok := blockSep[n.DataAtom]
+	if !w.t.o.Blocks {
+		ok = ok || n.DataAtom==atom.Td || n.DataAtom==atom.Th
+		if ok { w.t.space = w.t.started }
+		w.Children(n)
+		if ok { w.t.space = true }
+		return
+	}
+	if ok { w.t.block(sep) }
+	w.Children(n)
+	if ok { w.t.block(sep) }
+}
+
+// Converts n (including n itself) to Markdown and writes it to w.
+func (m *Markdown) Render(w io.Writer,n *html.Node) error {
+	mw := m.writer(w,true)
+	mw.Node(n)
+	if mw.t.started { mw.t.raw("\n") }
+	return mw.t.err
+}
+
+// Like Render, but returns the Markdown as string.
+func (m *Markdown) String(n *html.Node) string {
+	w := &bytes.Buffer{}
+	m.Render(w,n)
+	return w.String()
+}
+
+// Converts n to Markdown using the default settings.
+func ToMarkdown(n *html.Node) string {
+	return defaultMarkdown.String(n)
+}
@@ -0,0 +1,50 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package htmlscrape
+
+import (
+	"golang.org/x/net/html"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct{ in,want string }{
+		{"<h1>T</h1><p>a <em>b</em> <strong>c</strong> <code>x*y</code> <a href=\"/u\">l</a> <img src=\"i.png\" alt=\"A\"></p>","# T\n\na *b* **c** `x*y` [l](/u) ![A](i.png)\n"},
+		{"<ul><li>a</li><li>b<ol><li>c</li></ol></li></ul>","- a\n- b\n  1. c\n"},
+		{"<blockquote><p>q1</p><p>q2</p></blockquote><pre><code>func() {\n}</code></pre>","> q1\n>\n> q2\n\n```\nfunc() {\n}\n```\n"},
+		{"<table><tr><th>a</th><th>b|c</th></tr><tr><td>1</td><td>2</td></tr></table>","| a | b\\|c |\n| --- | --- |\n| 1 | 2 |\n"},
+		{"<p>1*2 &lt;script&gt; [x]</p><hr>","1\\*2 \\<script\\> \\[x\\]\n\n---\n"},
+		{"<p># not heading</p><p> - no list</p><p>+ no list</p><p>1. no list</p><p>2) no list</p><p>=</p><p>a # b - c 1. d</p>","\\# not heading\n\n\\- no list\n\n\\+ no list\n\n1\\. no list\n\n2\\) no list\n\n\\=\n\na # b - c 1. d\n"},
+		{"<blockquote>&gt; q</blockquote><ul><li># x</li></ul><p>a<br>- b</p>","> \\> q\n\n- \\# x\n\na\\\n\\- b\n"},
+		{"<p><a href=\"javascript:alert(1)\">x</a> <a href=\" JavaScript:y\">y</a> <a href=\"mailto:a@b\">m</a> <img src=\"data:text/html,x\" alt=\"i\"></p>","x y [m](mailto:a@b)\n"},
+	}
+	for _,tt := range tests {
+		if got := ToMarkdown(doc(t,tt.in)); got!=tt.want { t.Errorf("%s:\n got %q\nwant %q",tt.in,got,tt.want) }
+	}
+}
+
+func TestMarkdownRules(t *testing.T) {
+	m := &Markdown{Bullet:"*",Rules:map[string]MarkdownRule{
+		"video": func(w *MarkdownWriter,n *html.Node) bool {
+			w.Raw("[video]("+Attr(n,"src")+")")
+			return true
+		},
+		"b": func(w *MarkdownWriter,n *html.Node) bool { return false },
+	}}
+	got := m.String(doc(t,`<ul><li>x</li></ul><p><video src="v.mp4"></video> <b>bold</b></p>`))
+	if want := "* x\n\n[video](v.mp4) **bold**\n"; got!=want { t.Errorf("got %q, want %q",got,want) }
+}
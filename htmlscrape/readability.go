@@ -0,0 +1,345 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package htmlscrape
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+var positiveName = regexp.MustCompile(`(?i)article|body|content|entry|hentry|main|page|post|text|blog|story`)
+var bylineName = regexp.MustCompile(`(?i)byline|author`)
+var negativeName = regexp.MustCompile(`(?i)comment|footer|footnote|sidebar|sponsor|widget|nav|menu|banner|masthead|advert|\bads?\b|share|social|related|promo|popup|cookie|subscribe`)
+
+// Metadata and main content of an article page, see ExtractArticle.
+type Article struct{
+	// The element containing the main content, or nil if none was found.
+	Content     *html.Node
+
+	Title       string
+	Byline      string
+	Published   string
+	Image       string
+	Description string
+	SiteName    string
+}
+
+// elements, that never contain the main content.
+func skipped(h *html.Node) bool {
+	switch h.DataAtom {
+	case atom.Script,atom.Style,atom.Noscript,atom.Template,atom.Nav,atom.Header,
+		atom.Footer,atom.Aside,atom.Form,atom.Iframe,atom.Button,atom.Select:
+		return true
+	}
+	return false
+}
+
+func classWeight(h *html.Node) float64 {
+	w := 0.0
+	for _,k := range []string{"class","id"} {
+		v := findAttr(h,k)
+		if v=="" { continue }
+		if negativeName.MatchString(v) { w -= 25 }
+		if positiveName.MatchString(v) { w += 25 }
+	}
+	switch h.DataAtom {
+	case atom.Article,atom.Main:
+		w += 25
+	}
+	if findAttr(h,"role")=="main" || findAttr(h,"itemprop")=="articleBody" { w += 25 }
+	return w
+}
+
+func tagWeight(h *html.Node) float64 {
+	switch h.DataAtom {
+	case atom.Div,atom.Article,atom.Main,atom.Section:
+		return 5
+	case atom.Pre,atom.Td,atom.Blockquote:
+		return 3
+	case atom.Address,atom.Ol,atom.Ul,atom.Dl,atom.Dd,atom.Dt,atom.Li:
+		return -3
+	case atom.H1,atom.H2,atom.H3,atom.H4,atom.H5,atom.H6,atom.Th:
+		return -5
+	}
+	return 0
+}
+
+func textLen(h *html.Node) int {
+	return len(strings.TrimSpace(whiteSpace.ReplaceAllString(ExtractText(h)," ")))
+}
+
+// The fraction of text within h, that is part of a link.
+func LinkDensity(h *html.Node) float64 {
+	total := textLen(h)
+	if total==0 { return 0 }
+	links := 0
+	for _,a := range FindAll(h,"a") { links += textLen(a) }
+	return float64(links)/float64(total)
+}
+
+type scorer struct{
+	scores map[*html.Node]float64
+
+	// the length of the text and of the link text of every element.
+	text   map[*html.Node]int
+	links  map[*html.Node]int
+}
+
+func newScorer() *scorer {
+	return &scorer{make(map[*html.Node]float64),make(map[*html.Node]int),make(map[*html.Node]int)}
+}
+
+// measures the text lengths of n and its descendants in a single pass.
+func (s *scorer) measure(n *html.Node) (text,links int) {
+	switch n.Type {
+	case html.TextNode:
+		return len(whiteSpace.ReplaceAllString(n.Data," ")),0
+	case html.ElementNode,html.DocumentNode:
+	default:
+		return 0,0
+	}
+	for c := n.FirstChild; c!=nil; c = c.NextSibling {
+		t,l := s.measure(c)
+		text += t
+		links += l
+	}
+	if n.DataAtom==atom.A { links = text }
+	s.text[n],s.links[n] = text,links
+	return
+}
+
+// like LinkDensity, but uses the lengths measured before.
+func (s *scorer) density(n *html.Node) float64 {
+	if s.text[n]==0 { return 0 }
+	return float64(s.links[n])/float64(s.text[n])
+}
+
+func (s *scorer) init(h *html.Node) {
+	if _,ok := s.scores[h]; ok { return }
+	s.scores[h] = tagWeight(h)+classWeight(h)
+}
+
+func (s *scorer) score(begin, end *html.Node) {
+	if begin==nil { return }
+	for {
+		switch begin.Type {
+		case html.ElementNode:
+			if skipped(begin) || negativeName.MatchString(findAttr(begin,"class")+" "+findAttr(begin,"id")) &&
+				!positiveName.MatchString(findAttr(begin,"class")+" "+findAttr(begin,"id")) {
+				break
+			}
+			switch begin.DataAtom {
+			case atom.P,atom.Pre,atom.Td,atom.Blockquote:
+				s.paragraph(begin)
+			default:
+				s.score(begin.FirstChild,begin.LastChild)
+			}
+		case html.DocumentNode:
+			s.score(begin.FirstChild,begin.LastChild)
+		}
+		if end==begin { return }
+		begin = begin.NextSibling
+	}
+}
+
+func (s *scorer) paragraph(p *html.Node) {
+	t := strings.TrimSpace(whiteSpace.ReplaceAllString(ExtractText(p)," "))
+	if len(t)<25 { return }
+	sc := 1+float64(strings.Count(t,","))
+	if l := float64(len(t))/100; l<3 { sc += l } else { sc += 3 }
+	div := 1.0
+	for n := p.Parent; n!=nil && n.Type==html.ElementNode && div<=4; n = n.Parent {
+		s.init(n)
+		s.scores[n] += sc/div
+		div *= 2
+	}
+}
+
+/*
+ Finds the element, that most likely contains the main content of doc, and
+ returns a copy of it, from which navigation, asides, ads, forms, scripts and
+ link lists are removed. The doc is not modified.
+ */
+func FindContent(doc *html.Node) *html.Node {
+	s := newScorer()
+	s.measure(doc)
+	s.score(doc,doc)
+	var best *html.Node
+	bestScore := 0.0
+	for _,n := range collect(doc,doc,nil) {
+		sc,ok := s.scores[n]
+		if !ok || n.DataAtom==atom.Body || n.DataAtom==atom.Html { continue }
+		sc *= 1-s.density(n)
+		if best==nil || sc>bestScore {
+			best,bestScore = n,sc
+		}
+	}
+	if best==nil {
+		for _,sel := range []string{"article","main"} {
+			if a := LurkFor(doc,sel); a!=nil {
+				best = a
+				break
+			}
+		}
+	}
+	if best==nil { _,best = FindTB(doc) }
+	if best==nil { return nil }
+	return s.clean(best)
+}
+
+// elements, that are removed from the main content.
+func stripped(h *html.Node) bool {
+	switch h.DataAtom {
+	case atom.Script,atom.Style,atom.Noscript,atom.Template,atom.Nav,atom.Aside,
+		atom.Footer,atom.Form,atom.Iframe,atom.Button,atom.Select,atom.Object,atom.Embed:
+		return true
+	}
+	return false
+}
+
+// returns a copy of the content n without the boilerplate nested within it.
+func (s *scorer) clean(n *html.Node) *html.Node {
+	c := Clone(n)
+	orig := collect(n,n,nil)
+	copies := collect(c,c,nil)
+	for i,h := range copies {
+		if h==c || h.Type!=html.ElementNode || !attached(h,c) { continue }
+		o := orig[i]
+		cls := findAttr(h,"class")+" "+findAttr(h,"id")
+		switch {
+		case stripped(h):
+		case negativeName.MatchString(cls) && !positiveName.MatchString(cls):
+		case h.DataAtom==atom.Div || h.DataAtom==atom.Section || h.DataAtom==atom.Ul || h.DataAtom==atom.Ol:
+			// link lists, such as "related articles".
+			if s.text[o]>=200 || s.density(o)<=0.5 { continue }
+		default:
+			continue
+		}
+		Detach(h)
+	}
+	return c
+}
+
+func metaContent(doc *html.Node,keys ...string) string {
+	metas := FindAll(doc,"meta")
+	for _,k := range keys {
+		for _,m := range metas {
+			if findAttr(m,"property")==k || findAttr(m,"name")==k || findAttr(m,"itemprop")==k {
+				if v := strings.TrimSpace(findAttr(m,"content")); v!="" { return v }
+			}
+		}
+	}
+	return ""
+}
+
+// returns the string value of a JSON-LD property (strings, {"name":..}, {"url":..} or lists thereof).
+func ldString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _,e := range t {
+			if s := ldString(e); s!="" { return s }
+		}
+	case map[string]interface{}:
+		for _,k := range []string{"name","url","@id"} {
+			if s,ok := t[k].(string); ok && s!="" { return s }
+		}
+	}
+	return ""
+}
+
+// collects JSON-LD objects, that describe an article.
+func ldObjects(v interface{},l []map[string]interface{}) []map[string]interface{} {
+	switch t := v.(type) {
+	case []interface{}:
+		for _,e := range t { l = ldObjects(e,l) }
+	case map[string]interface{}:
+		if g,ok := t["@graph"]; ok { l = ldObjects(g,l) }
+		if typ := ldString(t["@type"]); strings.HasSuffix(typ,"Article") || typ=="BlogPosting" || typ=="WebPage" {
+			l = append(l,t)
+		}
+	}
+	return l
+}
+
+func jsonLD(doc *html.Node) []map[string]interface{} {
+	var l []map[string]interface{}
+	for _,sc := range FindAll(doc,"script") {
+		if findAttr(sc,"type")!="application/ld+json" { continue }
+		var v interface{}
+		if json.Unmarshal([]byte(ExtractText(sc)),&v)!=nil { continue }
+		l = ldObjects(v,l)
+	}
+	return l
+}
+
+func ldProp(objs []map[string]interface{},k string) string {
+	for _,o := range objs {
+		if s := strings.TrimSpace(ldString(o[k])); s!="" { return s }
+	}
+	return ""
+}
+
+func first(s ...string) string {
+	for _,e := range s {
+		if e!="" { return e }
+	}
+	return ""
+}
+
+/*
+ Extracts the main content of an article page, discarding navigation, ads,
+ footers and alike. The candidate elements are scored by the amount of text
+ in their paragraphs, their link density, their tag names and their class
+ and id attributes (similar to Arc90's Readability).
+
+ The metadata is taken from OpenGraph and other <meta> tags, JSON-LD and
+ the document itself. The doc is not modified, Content is a cleaned copy
+ (see FindContent).
+ */
+func ExtractArticle(doc *html.Node) *Article {
+	ld := jsonLD(doc)
+	a := &Article{Content:FindContent(doc)}
+	title := ""
+	if t,_ := FindTB(doc); t!=nil { title = strings.TrimSpace(ExtractText(t)) }
+	a.Title = first(metaContent(doc,"og:title","twitter:title"),ldProp(ld,"headline"),title)
+	a.Byline = first(metaContent(doc,"author","article:author","byl"),ldProp(ld,"author"))
+	if a.Byline=="" {
+		for _,n := range collect(doc,doc,nil) {
+			if n.Type!=html.ElementNode { continue }
+			if findAttr(n,"rel")=="author" || findAttr(n,"itemprop")=="author" ||
+				bylineName.MatchString(findAttr(n,"class")) {
+				if t := strings.TrimSpace(whiteSpace.ReplaceAllString(ExtractText(n)," ")); t!="" && len(t)<100 {
+					a.Byline = t
+					break
+				}
+			}
+		}
+	}
+	a.Published = first(metaContent(doc,"article:published_time","datePublished","date","dc.date"),ldProp(ld,"datePublished"))
+	if a.Published=="" && a.Content!=nil {
+		if t := LurkFor(a.Content,"time"); t!=nil { a.Published = findAttr(t,"datetime") }
+	}
+	a.Image = first(metaContent(doc,"og:image","og:image:url","twitter:image"),ldProp(ld,"image"))
+	a.Description = first(metaContent(doc,"og:description","description","twitter:description"),ldProp(ld,"description"))
+	a.SiteName = first(metaContent(doc,"og:site_name","application-name"),ldProp(ld,"publisher"))
+	return a
+}
@@ -0,0 +1,78 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package htmlscrape
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const articlePage = `<html><head><title>Page title</title>
+<meta property="og:title" content="The Headline">
+<meta property="og:image" content="http://example.com/lead.jpg">
+<meta name="author" content="Jane Doe">
+<script type="application/ld+json">{"@type":"NewsArticle","datePublished":"2024-01-02T03:04:05Z","publisher":{"name":"Example News"}}</script>
+</head><body>
+<nav><ul><li><a href="/">Home</a></li><li><a href="/news">News</a></li></ul></nav>
+<div class="sidebar"><p>Subscribe to our newsletter, it is really great, honestly.</p></div>
+<article><h1>The Headline</h1>
+<p>The first paragraph of the article, which is long enough to be scored, with some commas, too.</p>
+<aside>Read also: something else</aside>
+<div class="ad-banner advert">Buy things now, this is an advertisement you really want to see!</div>
+<p>The second paragraph of the article, which is also long enough, and it has commas, as well.</p>
+<ul class="links"><li><a href="/a">Related one</a></li><li><a href="/b">Related two</a></li></ul>
+<script>track()</script>
+</article>
+<footer><p>Copyright notice, all rights reserved, do not copy this page please.</p></footer>
+</body></html>`
+
+func TestFindContent(t *testing.T) {
+	d := doc(t,articlePage)
+	before := render(d)
+	c := FindContent(d)
+	if c==nil || c.Data!="article" { t.Fatalf("got %v, want <article>",c) }
+	txt := ExtractText(c)
+	for _,s := range []string{"first paragraph","second paragraph"} {
+		if !strings.Contains(txt,s) { t.Errorf("content lacks %q",s) }
+	}
+	for _,s := range []string{"Read also","advertisement","Related one","track()","Home","Copyright"} {
+		if strings.Contains(txt,s) { t.Errorf("content contains %q",s) }
+	}
+	if render(d)!=before { t.Errorf("the document was modified") }
+}
+
+func TestExtractArticle(t *testing.T) {
+	a := ExtractArticle(doc(t,articlePage))
+	want := Article{Title:"The Headline",Byline:"Jane Doe",Published:"2024-01-02T03:04:05Z",Image:"http://example.com/lead.jpg",SiteName:"Example News"}
+	got := *a
+	got.Content = nil
+	if got!=want { t.Errorf("got %+v\nwant %+v",got,want) }
+}
+
+func TestLinkDensity(t *testing.T) {
+	b := body(t,`<div><a href="/">abcd</a>efgh</div>`)
+	if d := LinkDensity(b.FirstChild); d!=0.5 { t.Errorf("got %v, want 0.5",d) }
+}
+
+// the link densities are measured once, so deep nesting does not take quadratic time.
+func TestFindContentDeep(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i<2000; i++ { fmt.Fprintf(&sb,`<div class="content"><p>Paragraph %d with enough text to be scored, really, yes.</p>`,i) }
+	for i := 0; i<2000; i++ { sb.WriteString(`</div>`) }
+	if c := FindContent(doc(t,sb.String())); c==nil { t.Errorf("no content found") }
+}
@@ -0,0 +1,161 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package htmlscrape
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"strings"
+)
+
+func atomSet(names string) map[atom.Atom]bool {
+	m := make(map[atom.Atom]bool)
+	for _,n := range strings.Fields(names) {
+		a := atom.Lookup([]byte(n))
+		if a==0 { panic("htmlscrape: unknown element "+n) }
+		m[a] = true
+	}
+	return m
+}
+
+// Elements, that Sanitize keeps. Other elements are replaced by their content.
+var safeElements = atomSet(`a abbr address article aside b bdi bdo blockquote body br caption
+	cite code col colgroup data dd del details dfn div dl dt em figcaption figure footer h1 h2 h3
+	h4 h5 h6 head header hgroup hr html i img ins kbd li main mark nav ol p picture pre q rp rt
+	ruby s samp section small source span strong sub summary sup table tbody td tfoot th thead
+	time tr u ul var wbr`)
+
+// Elements, that Sanitize removes including their content.
+var unsafeElements = atomSet(`script style template noscript iframe frame frameset noframes
+	object embed applet svg math textarea select option title xmp plaintext noembed`)
+
+// Attributes, that Sanitize keeps on every element.
+var safeAttrs = map[string]bool{"class":true,"id":true,"title":true,"lang":true,"dir":true}
+
+// Attributes, that Sanitize keeps on some elements.
+var safeElemAttrs = map[atom.Atom]map[string]bool{
+	atom.A:          {"href":true,"hreflang":true,"rel":true,"name":true},
+	atom.Img:        {"src":true,"srcset":true,"sizes":true,"alt":true,"width":true,"height":true,"loading":true},
+	atom.Source:     {"src":true,"srcset":true,"sizes":true,"media":true,"type":true},
+	atom.Td:         {"colspan":true,"rowspan":true,"headers":true},
+	atom.Th:         {"colspan":true,"rowspan":true,"headers":true,"scope":true,"abbr":true},
+	atom.Col:        {"span":true},
+	atom.Colgroup:   {"span":true},
+	atom.Ol:         {"start":true,"reversed":true,"type":true},
+	atom.Li:         {"value":true},
+	atom.Blockquote: {"cite":true},
+	atom.Q:          {"cite":true},
+	atom.Del:        {"cite":true,"datetime":true},
+	atom.Ins:        {"cite":true,"datetime":true},
+	atom.Time:       {"datetime":true},
+	atom.Data:       {"value":true},
+	atom.Details:    {"open":true},
+}
+
+// URL schemes, that Sanitize keeps. URLs without a scheme are relative.
+var safeSchemes = map[string]bool{"http":true,"https":true,"mailto":true}
+
+// the image types, that may be embedded as data: URL.
+var safeImages = []string{"data:image/png","data:image/jpeg","data:image/gif","data:image/webp"}
+
+// reports, whether the URL s is safe. Data URLs of images are allowed, if img is set.
+func safeURL(s string,img bool) bool {
+	s = strings.ToLower(strings.Map(func(r rune) rune {
+		if r<=' ' { return -1 }
+		return r
+	},s))
+	i := strings.IndexAny(s,":/?#")
+	if i<0 || s[i]!=':' { return true }
+	if img && strings.HasPrefix(s,"data:") {
+		for _,t := range safeImages {
+			if strings.HasPrefix(s,t+";") || strings.HasPrefix(s,t+",") { return true }
+		}
+		return false
+	}
+	return safeSchemes[s[:i]]
+}
+
+// reports, whether every URL of the srcset attribute v is safe.
+func safeSrcset(v string) bool {
+	for _,c := range strings.Split(v,",") {
+		f := strings.Fields(c)
+		if len(f)>0 && !safeURL(f[0],false) { return false }
+	}
+	return true
+}
+
+/*
+ A Transf, that makes scraped HTML safe for the inclusion into a page. It
+ keeps only an allowlist of elements, attributes and URL schemes:
+
+ Formatting, structural, list, table and image elements are kept. Active
+ content (scripts, styles, frames, plugins, SVG and MathML) and form
+ controls, that contain text, are removed with their content; All other
+ elements (eg. form, button, font) are replaced by their content. Comments
+ are removed as well.
+
+ The attributes class, id, title, lang and dir are kept, as well as some
+ attributes of certain elements (eg. href of a, src and alt of img, colspan
+ of td). Styles and event handlers are removed. URLs must be relative or
+ use http, https or mailto; Images may also use data: URLs of PNG, JPEG, GIF
+ and WebP images.
+
+ Use it with Walk, eg. htmlscrape.Walk(n,htmlscrape.Sanitize). If n itself
+ is unsafe, it is emptied, eg. a selected <script> becomes <script></script>.
+ */
+func Sanitize(h *html.Node){
+	switch h.Type {
+	case html.CommentNode:
+		Detach(h)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+	if unsafeElements[h.DataAtom] || h.Namespace!="" {
+		// the root of the Walk is still rendered by the caller, so it is emptied as well.
+		for h.FirstChild!=nil { h.RemoveChild(h.FirstChild) }
+		h.Attr = nil
+		Detach(h)
+		return
+	}
+	if !safeElements[h.DataAtom] {
+		h.Attr = nil
+		if h.Parent!=nil {
+			UnwrapNode(h)
+			return
+		}
+		// the root can not be unwrapped.
+		return
+	}
+	attr := h.Attr[:0]
+	for _,a := range h.Attr {
+		k := strings.ToLower(a.Key)
+		switch {
+		case a.Namespace!="":
+			continue
+		case !safeAttrs[k] && !safeElemAttrs[h.DataAtom][k]:
+			continue
+		case k=="srcset" && !safeSrcset(a.Val):
+			continue
+		case urlAttrs[k] && !safeURL(a.Val,k=="src" && h.DataAtom==atom.Img):
+			continue
+		}
+		attr = append(attr,a)
+	}
+	h.Attr = attr
+}
@@ -0,0 +1,80 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package htmlscrape
+
+import (
+	"golang.org/x/net/html"
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct{ in,want string }{
+		{`<p class="x" style="color:red" onclick="evil()">text</p>`,`<p class="x">text</p>`},
+		{`<script>alert(1)</script><style>p{}</style>ok`,`ok`},
+		{`<a href="javascript:alert(1)">a</a>`,`<a>a</a>`},
+		{`<a href=" JaVa&#x09;Script:alert(1)">a</a>`,`<a>a</a>`},
+		{`<a href="vbscript:x">a</a><a href="tel:123">b</a>`,`<a>a</a><a>b</a>`},
+		{`<a href="/rel?x=1#f" target="_blank" rel="nofollow">a</a>`,`<a href="/rel?x=1#f" rel="nofollow">a</a>`},
+		{`<a href="https://x.org/a:b">a</a><a href="mailto:a@b">m</a><a href="a/b:c">r</a>`,`<a href="https://x.org/a:b">a</a><a href="mailto:a@b">m</a><a href="a/b:c">r</a>`},
+		{`<img src="data:image/png;base64,AAAA" alt="a">`,`<img src="data:image/png;base64,AAAA" alt="a"/>`},
+		{`<img src="data:image/svg+xml,<svg/>"><img src="data:text/html,x">`,`<img/><img/>`},
+		{`<a href="data:image/png;base64,AAAA">a</a>`,`<a>a</a>`},
+		{`<img srcset="/a.png 1x, javascript:x 2x"><img srcset="/a.png 1x, /b.png 2x">`,`<img/><img srcset="/a.png 1x, /b.png 2x"/>`},
+		{`<form action="/x"><input name="a"><button>Go</button><textarea>t</textarea></form>`,`Go`},
+		{`<font color="red"><b>bold</b></font><center>c</center>`,`<b>bold</b>c`},
+		{`<svg><script>x</script></svg><math><mi>x</mi></math>m`,`m`},
+		{`<iframe src="/x"></iframe><object data="/x"></object><embed src="/x">e`,`e`},
+		{`<!-- comment --><table><tr><td colspan="2" onmouseover="x">c</td></tr></table>`,`<table><tbody><tr><td colspan="2">c</td></tr></tbody></table>`},
+		{`<my-widget data-x="1"><i>in</i></my-widget>`,`<i>in</i>`},
+		{`<div xmlns:x="y" x:attr="z" id="d" title="t" lang="en">d</div>`,`<div id="d" title="t" lang="en">d</div>`},
+		{`<blockquote cite="javascript:x">q</blockquote><q cite="/src">q</q>`,`<blockquote>q</blockquote><q cite="/src">q</q>`},
+	}
+	for _,tt := range tests {
+		b := body(t,tt.in)
+		Walk(b,Sanitize)
+		if got := render(b); got!=tt.want { t.Errorf("%s:\n got %s\nwant %s",tt.in,got,tt.want) }
+	}
+}
+
+func TestSanitizeDocument(t *testing.T) {
+	d := doc(t,`<html><head><title>T</title><meta http-equiv="refresh" content="0;url=javascript:x"><link rel="stylesheet" href="/s.css"></head><body onload="x()"><p>x</p></body></html>`)
+	Walk(d,Sanitize)
+	if got := render(d); got!=`<html><head></head><body><p>x</p></body></html>` { t.Errorf("got %s",got) }
+}
+
+func TestSanitizeRoot(t *testing.T) {
+	for _,tt := range []struct{ in,sel,want string }{
+		{`<script>alert(1)</script>`,"script",`<script></script>`},
+		{`<iframe src="javascript:x" srcdoc="<script>x</script>"><b>x</b></iframe>`,"iframe",`<iframe></iframe>`},
+		{`<svg onload="x"><script>x</script></svg>`,"svg",`<svg></svg>`},
+	} {
+		n := LurkFor(body(t,tt.in),tt.sel)
+		// the selected node, as it is and as a parentless clone.
+		for _,r := range []*html.Node{Clone(n),n} {
+			Walk(r,Sanitize)
+			buf := new(strings.Builder)
+			html.Render(buf,r)
+			if got := buf.String(); got!=tt.want { t.Errorf("%s (parent %v): got %s, want %s",tt.in,r.Parent!=nil,got,tt.want) }
+		}
+	}
+	r := Clone(LurkFor(body(t,`<font color="red" onclick="x"><b>bold</b></font>`),"font"))
+	Walk(r,Sanitize)
+	buf := new(strings.Builder)
+	html.Render(buf,r)
+	if got := buf.String(); got!=`<font><b>bold</b></font>` { t.Errorf("got %s",got) }
+}
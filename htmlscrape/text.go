@@ -0,0 +1,348 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package htmlscrape
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type TextLayout int
+const (
+	// Lists are rendered with "-" or "1." markers, table cells are separated by tabs.
+	TextPlain TextLayout = iota
+
+	// Lists, tables (GFM), links, headings and <pre> blocks are rendered as Markdown.
+	TextMarkdown
+)
+
+// Options for RenderText.
+type TextOptions struct{
+	// Skip the content of <script>, <style>, <template> and <noscript>.
+	SkipScript bool
+
+	// Emit line breaks at the boundaries of block elements and for <br>. Lists
+	// and tables are only rendered according to Layout, if Blocks is true.
+	Blocks     bool
+
+	// Collapse runs of whitespace into a single space (except within <pre>).
+	Collapse   bool
+
+	Layout     TextLayout
+
+	// Append the URL of links in the TextPlain layout: "text (url)".
+	Links      bool
+}
+
+// The options used by Text, if nil is passed.
+var DefaultText = &TextOptions{SkipScript:true,Blocks:true,Collapse:true}
+
+var blockSep = map[atom.Atom]int{
+	atom.P:2, atom.H1:2, atom.H2:2, atom.H3:2, atom.H4:2, atom.H5:2, atom.H6:2,
+	atom.Ul:2, atom.Ol:2, atom.Dl:2, atom.Table:2, atom.Blockquote:2, atom.Pre:2, atom.Hr:2,
+	atom.Div:1, atom.Li:1, atom.Tr:1, atom.Dt:1, atom.Dd:1, atom.Section:1, atom.Article:1,
+	atom.Header:1, atom.Footer:1, atom.Nav:1, atom.Aside:1, atom.Main:1, atom.Figure:1,
+	atom.Figcaption:1, atom.Address:1, atom.Form:1, atom.Fieldset:1, atom.Details:1,
+	atom.Summary:1, atom.Caption:1,
+}
+
+type textWriter struct{
+	w       io.Writer
+	o       *TextOptions
+	nl      int  // pending line breaks
+	brk     int  // line breaks emitted since the last text
+	bol     bool // at the beginning of a line, the prefix has not been written yet
+	space   bool
+	started bool
+	prefix  string
+	pre     int
+	lists   int
+	err     error
+}
+
+func (t *textWriter) raw(s string) {
+	if t.err==nil { _,t.err = io.WriteString(t.w,s) }
+}
+
+func (t *textWriter) block(n int) {
+	if !t.o.Blocks { t.space = true; return }
+	if n>t.nl { t.nl = n }
+	t.space = false
+}
+
+// emits the pending line breaks.
+func (t *textWriter) breaks() {
+	if !t.started { t.nl = 0; return }
+	for ; t.brk<t.nl; t.brk++ {
+		if t.bol { t.raw(strings.TrimRight(t.prefix," ")) }
+		t.raw("\n")
+		t.bol = true
+	}
+	t.nl = 0
+}
+
+// prepares the output for the next text.
+func (t *textWriter) flush() {
+	t.breaks()
+	if !t.started || t.bol {
+		t.raw(t.prefix)
+	} else if t.space {
+		t.raw(" ")
+	}
+	t.started = true
+	t.bol = false
+	t.brk = 0
+	t.space = false
+}
+
+// writes s verbatim, but prefixes every line.
+func (t *textWriter) verbatim(s string) {
+	if s=="" { return }
+	t.flush()
+	t.raw(strings.Replace(s,"\n","\n"+t.prefix,-1))
+}
+
+func (t *textWriter) text(s string) {
+	if !t.o.Collapse || t.pre>0 {
+		t.verbatim(s)
+		return
+	}
+	s = whiteSpace.ReplaceAllString(s," ")
+	if s=="" { return }
+	if s[0]==' ' {
+		if t.started { t.space = true }
+		s = s[1:]
+	}
+	trail := strings.HasSuffix(s," ")
+	s = strings.TrimSuffix(s," ")
+	if s!="" {
+		t.flush()
+		t.raw(s)
+	}
+	if trail { t.space = true }
+}
+
+func (t *textWriter) children(h *html.Node) {
+	for c := h.FirstChild; c!=nil; c = c.NextSibling { t.node(c) }
+}
+
+// renders h into a single line.
+func (t *textWriter) inline(h *html.Node) string {
+	o := *t.o
+	o.Blocks = false
+	o.Collapse = true
+	buf := &bytes.Buffer{}
+	s := &textWriter{w:buf,o:&o}
+	s.children(h)
+	return strings.TrimSpace(buf.String())
+}
+
+func (t *textWriter) list(h *html.Node) {
+	n := 1
+	if v,e := strconv.Atoi(findAttr(h,"start")); e==nil { n = v }
+	sep := blockSep[h.DataAtom]
+	if t.lists>0 { sep = 1 }
+	t.block(sep)
+	t.lists++
+	old := t.prefix
+	for c := h.FirstChild; c!=nil; c = c.NextSibling {
+		if c.Type!=html.ElementNode || c.DataAtom!=atom.Li {
+			t.node(c)
+			continue
+		}
+		marker := "- "
+		if h.DataAtom==atom.Ol {
+			marker = strconv.Itoa(n)+". "
+			n++
+		}
+		t.block(1)
+		t.flush()
+		t.raw(marker)
+		// absorb the line breaks of a leading block within the item.
+		t.brk = 2
+		t.prefix = old+strings.Repeat(" ",len(marker))
+		t.children(c)
+		t.prefix = old
+		t.block(1)
+	}
+	t.lists--
+	t.block(sep)
+}
+
+func tableRows(h *html.Node,rows [][]*html.Node) [][]*html.Node {
+	for c := h.FirstChild; c!=nil; c = c.NextSibling {
+		if c.Type!=html.ElementNode { continue }
+		switch c.DataAtom {
+		case atom.Thead,atom.Tbody,atom.Tfoot:
+			rows = tableRows(c,rows)
+		case atom.Tr:
+			var cells []*html.Node
+			for d := c.FirstChild; d!=nil; d = d.NextSibling {
+				if d.DataAtom==atom.Td || d.DataAtom==atom.Th { cells = append(cells,d) }
+			}
+			rows = append(rows,cells)
+		}
+	}
+	return rows
+}
+
+func (t *textWriter) table(h *html.Node) {
+	t.block(2)
+	if c := LurkFor(h,"caption"); c!=nil {
+		t.text(t.inline(c))
+		t.block(1)
+	}
+	for i,row := range tableRows(h,nil) {
+		cells := make([]string,len(row))
+		for j,c := range row {
+			cells[j] = t.inline(c)
+			if t.o.Layout==TextMarkdown { cells[j] = strings.Replace(cells[j],"|",`\|`,-1) }
+		}
+		t.block(1)
+		t.flush()
+		if t.o.Layout==TextMarkdown {
+			t.raw("| "+strings.Join(cells," | ")+" |")
+			if i==0 {
+				t.raw("\n"+t.prefix+"|"+strings.Repeat(" --- |",len(cells)))
+			}
+		} else {
+			t.raw(strings.Join(cells,"\t"))
+		}
+	}
+	t.block(2)
+}
+
+func (t *textWriter) node(h *html.Node) {
+	switch h.Type {
+	case html.TextNode:
+		t.text(h.Data)
+		return
+	case html.DocumentNode:
+		t.children(h)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+	switch h.DataAtom {
+	case atom.Script,atom.Style,atom.Template,atom.Noscript:
+		if t.o.SkipScript { return }
+	case atom.Br:
+		if t.o.Blocks {
+			t.flush()
+			t.raw("\n")
+			t.bol = true
+			t.brk = 1
+		} else {
+			t.space = true
+		}
+		return
+	}
+	if !t.o.Blocks {
+		_,ok := blockSep[h.DataAtom]
+		ok = ok || h.DataAtom==atom.Td || h.DataAtom==atom.Th
+		if ok { t.space = t.started }
+		t.children(h)
+		if ok { t.space = true }
+		return
+	}
+	md := t.o.Layout==TextMarkdown
+	switch h.DataAtom {
+	case atom.Ul,atom.Ol:
+		t.list(h)
+		return
+	case atom.Table:
+		t.table(h)
+		return
+	case atom.Hr:
+		t.block(2)
+		if md { t.flush(); t.raw("---") }
+		t.block(2)
+		return
+	case atom.H1,atom.H2,atom.H3,atom.H4,atom.H5,atom.H6:
+		if md {
+			t.block(2)
+			t.flush()
+			t.raw(strings.Repeat("#",int(h.Data[1]-'0'))+" ")
+			t.text(t.inline(h))
+			t.block(2)
+			return
+		}
+	case atom.Pre:
+		t.block(2)
+		if md { t.flush(); t.raw("```\n"+t.prefix) }
+		t.pre++
+		t.children(h)
+		t.pre--
+		if md { t.raw("\n"+t.prefix+"```") }
+		t.block(2)
+		return
+	case atom.Blockquote:
+		if md {
+			t.block(2)
+			t.breaks()
+			old := t.prefix
+			t.prefix += "> "
+			t.children(h)
+			t.prefix = old
+			t.block(2)
+			return
+		}
+	case atom.A:
+		href := findAttr(h,"href")
+		if href=="" || (!md && !t.o.Links) { break }
+		s := t.inline(h)
+		if md {
+			t.text("["+s+"]("+href+")")
+		} else if s=="" || s==href {
+			t.text(href)
+		} else {
+			t.text(s+" ("+href+")")
+		}
+		return
+	case atom.Img:
+		if md { t.text("!["+findAttr(h,"alt")+"]("+findAttr(h,"src")+")") }
+		return
+	}
+	sep,ok := blockSep[h.DataAtom]
+	if ok { t.block(sep) }
+	t.children(h)
+	if ok { t.block(sep) }
+}
+
+/*
+ Renders the text content of h into w according to the options o. If o is
+ nil, DefaultText is used. Unlike ExtractTextIO, the output is laid out: See
+ TextOptions.
+ */
+func RenderText(w io.Writer,h *html.Node,o *TextOptions) error {
+	if o==nil { o = DefaultText }
+	t := &textWriter{w:w,o:o}
+	t.node(h)
+	return t.err
+}
+
+// Like RenderText, but returns the text as string.
+func Text(h *html.Node,o *TextOptions) string {
+	w := &bytes.Buffer{}
+	RenderText(w,h,o)
+	return w.String()
+}
@@ -0,0 +1,50 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package htmlscrape
+
+import (
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	md := &TextOptions{SkipScript:true,Blocks:true,Collapse:true,Layout:TextMarkdown}
+	links := &TextOptions{SkipScript:true,Blocks:true,Collapse:true,Links:true}
+	tests := []struct{
+		in   string
+		o    *TextOptions
+		want string
+	}{
+		{"<p>Hello   <b>big</b>\n world</p><p>second<br>line</p><script>x()</script><style>p{}</style>",nil,"Hello big world\n\nsecond\nline"},
+		{"<p>Hello   <b>big</b>\n world</p><script>x()</script>",&TextOptions{},"Hello   big\n world x()"},
+		// a block within a list item must not add an empty line.
+		{"<ul><li>a</li><li><p>b</p></li></ul><ol><li>x</li><li>y</li></ol>",nil,"- a\n- b\n\n1. x\n2. y"},
+		{"<ul><li>a<ul><li>b</li></ul></li></ul>",nil,"- a\n  - b"},
+		{"<table><tr><th>h1</th><th>h2</th></tr><tr><td>a</td><td>b</td></tr></table>",nil,"h1\th2\na\tb"},
+		{"<table><tr><th>h1</th><th>h2</th></tr><tr><td>a</td><td>b</td></tr></table>",md,"| h1 | h2 |\n| --- | --- |\n| a | b |"},
+		{"<p>see <a href=\"http://x/\">link</a></p><pre>  keep\n   this</pre><h2>Head</h2>",nil,"see link\n\n  keep\n   this\n\nHead"},
+		{"<p>see <a href=\"http://x/\">link</a></p><pre>  keep\n   this</pre><h2>Head</h2>",md,"see [link](http://x/)\n\n```\n  keep\n   this\n```\n\n## Head"},
+		{"<p>see <a href=\"http://x/\">link</a></p>",links,"see link (http://x/)"},
+		{"<template><p>t</p></template><noscript>n</noscript>v",nil,"v"},
+	}
+	for _,tt := range tests {
+		if got := Text(doc(t,tt.in),tt.o); got!=tt.want { t.Errorf("%s:\n got %q\nwant %q",tt.in,got,tt.want) }
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	if got := ExtractText(body(t,"<p>a<b>b</b></p>c")); got!="abc" { t.Errorf("got %q",got) }
+}
@@ -0,0 +1,216 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package htmlscrape
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"strings"
+)
+
+// Returns all nodes within n (including n), that match the selector sel.
+func FindAll(n *html.Node,sel string) []*html.Node{
+	var l []*html.Node
+	for _,c := range collect(n,n,nil) {
+		if Match(c,sel) { l = append(l,c) }
+	}
+	return l
+}
+
+/*
+ Returns a deep copy of n. The copy has no parent and no siblings, so it can
+ be modified without affecting the original tree (which may be shared).
+ */
+func Clone(n *html.Node) *html.Node{
+	c := &html.Node{Type:n.Type,DataAtom:n.DataAtom,Data:n.Data,Namespace:n.Namespace}
+	c.Attr = append([]html.Attribute(nil),n.Attr...)
+	for ch := n.FirstChild; ch!=nil; ch = ch.NextSibling {
+		c.AppendChild(Clone(ch))
+	}
+	return c
+}
+
+// Removes n from its parent, if any.
+func Detach(n *html.Node){
+	if n.Parent!=nil { n.Parent.RemoveChild(n) }
+}
+
+// Replaces n by its children.
+func UnwrapNode(n *html.Node){
+	if n.Parent==nil { return }
+	for c := n.FirstChild; c!=nil; c = n.FirstChild {
+		n.RemoveChild(c)
+		n.Parent.InsertBefore(c,n)
+	}
+	n.Parent.RemoveChild(n)
+}
+
+// Replaces n by the nodes nn. The nodes nn must not have a parent.
+func ReplaceNode(n *html.Node,nn ...*html.Node){
+	if n.Parent==nil { return }
+	for _,c := range nn {
+		n.Parent.InsertBefore(c,n)
+	}
+	n.Parent.RemoveChild(n)
+}
+
+/*
+ Puts w at the position of n and moves n into w. If w already has children, n
+ becomes the last child of the innermost first element child of w, so that w
+ may be something like <div><p></p></div>.
+ */
+func WrapNode(n,w *html.Node){
+	if n.Parent!=nil {
+		n.Parent.InsertBefore(w,n)
+		n.Parent.RemoveChild(n)
+	}
+	for {
+		c := w.FirstChild
+		for c!=nil && c.Type!=html.ElementNode { c = c.NextSibling }
+		if c==nil { break }
+		w = c
+	}
+	w.AppendChild(n)
+}
+
+// Parses src as HTML fragment within the context of the element ctx.
+func parseIn(ctx *html.Node,src string) []*html.Node{
+	if ctx==nil || ctx.Type!=html.ElementNode {
+		ctx = &html.Node{Type:html.ElementNode,Data:"body",DataAtom:atom.Body}
+	}
+	nn,e := html.ParseFragment(strings.NewReader(src),ctx)
+	if e!=nil { return nil }
+	return nn
+}
+
+// Removes every element matching sel (including its content).
+func Remove(sel string) Transf{
+	return func(h *html.Node){
+		if Match(h,sel) { Detach(h) }
+	}
+}
+
+// Replaces every element matching sel by its content.
+func Unwrap(sel string) Transf{
+	return func(h *html.Node){
+		if Match(h,sel) { UnwrapNode(h) }
+	}
+}
+
+// Replaces every element matching sel by the HTML fragment src.
+func Replace(sel string,src string) Transf{
+	return func(h *html.Node){
+		if Match(h,sel) && h.Parent!=nil { ReplaceNode(h,parseIn(h.Parent,src)...) }
+	}
+}
+
+// Changes the tag name of every element matching sel to tag.
+func Rename(sel string,tag string) Transf{
+	return func(h *html.Node){
+		if Match(h,sel) {
+			h.Data = tag
+			h.DataAtom = atom.Lookup([]byte(tag))
+		}
+	}
+}
+
+// Sets the attribute k of every element matching sel to v.
+func SetAttr(sel string,k,v string) Transf{
+	return func(h *html.Node){
+		if !Match(h,sel) { return }
+		for i,attr := range h.Attr {
+			if attr.Namespace=="" && attr.Key==k {
+				h.Attr[i].Val = v
+				return
+			}
+		}
+		h.Attr = append(h.Attr,html.Attribute{Key:k,Val:v})
+	}
+}
+
+// Removes the attributes k from every element matching sel.
+func RemoveAttr(sel string,k ...string) Transf{
+	return func(h *html.Node){
+		if !Match(h,sel) { return }
+		attrs := h.Attr[:0]
+		outer:
+		for _,attr := range h.Attr {
+			for _,kk := range k {
+				if attr.Namespace=="" && attr.Key==kk { continue outer }
+			}
+			attrs = append(attrs,attr)
+		}
+		h.Attr = attrs
+	}
+}
+
+// Adds the classes cls to every element matching sel.
+func AddClass(sel string,cls ...string) Transf{
+	return func(h *html.Node){
+		if !Match(h,sel) { return }
+		old := strings.TrimSpace(findAttr(h,"class"))
+		have := whiteSpace.Split(old,-1)
+		nw := old
+		outer:
+		for _,c := range cls {
+			for _,hc := range have {
+				if hc==c { continue outer }
+			}
+			if nw!="" { nw += " " }
+			nw += c
+			have = append(have,c)
+		}
+		if nw!=old { SetAttr("",`class`,nw)(h) }
+	}
+}
+
+// Inserts the HTML fragment src before every element matching sel.
+func InsertBefore(sel string,src string) Transf{
+	return func(h *html.Node){
+		if !Match(h,sel) || h.Parent==nil { return }
+		for _,c := range parseIn(h.Parent,src) {
+			h.Parent.InsertBefore(c,h)
+		}
+	}
+}
+
+// Inserts the HTML fragment src after every element matching sel.
+func InsertAfter(sel string,src string) Transf{
+	return func(h *html.Node){
+		if !Match(h,sel) || h.Parent==nil { return }
+		next := h.NextSibling
+		for _,c := range parseIn(h.Parent,src) {
+			h.Parent.InsertBefore(c,next)
+		}
+	}
+}
+
+/*
+ Wraps every element matching sel into the HTML fragment src, which must
+ consist of one element, eg. `<div class="figure"></div>`. See WrapNode.
+ */
+func Wrap(sel string,src string) Transf{
+	return func(h *html.Node){
+		if !Match(h,sel) || h.Parent==nil { return }
+		for _,w := range parseIn(h.Parent,src) {
+			if w.Type==html.ElementNode {
+				WrapNode(h,w)
+				return
+			}
+		}
+	}
+}
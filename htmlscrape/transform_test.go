@@ -0,0 +1,87 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package htmlscrape
+
+import (
+	"golang.org/x/net/html"
+	"testing"
+)
+
+func TestTransforms(t *testing.T) {
+	tests := []struct{
+		name string
+		tf   Transf
+		in   string
+		want string
+	}{
+		{"Remove",Remove(".ad"),`<p>a</p><div class="ad"><p>x</p></div><p>b</p><div class="ad">y</div>`,`<p>a</p><p>b</p>`},
+		{"Unwrap",Unwrap("span"),`<p><span>a<span>b</span></span>c</p>`,`<p>abc</p>`},
+		{"Replace",Replace("#x",`<b>new</b>`),`<p>a<i id="x">old</i></p>`,`<p>a<b>new</b></p>`},
+		{"Rename",Rename("b","strong"),`<b>x</b>`,`<strong>x</strong>`},
+		{"SetAttr",SetAttr("a","rel","nofollow"),`<a href="/" rel="me">x</a><a>y</a>`,`<a href="/" rel="nofollow">x</a><a rel="nofollow">y</a>`},
+		{"RemoveAttr",RemoveAttr("*","style","id"),`<p id="a" style="x" class="c">t</p>`,`<p class="c">t</p>`},
+		{"AddClass",AddClass("p","x","y"),`<p class="y z">t</p><p>u</p>`,`<p class="y z x">t</p><p class="x y">u</p>`},
+		{"InsertBefore",InsertBefore("h2",`<hr/>`),`<h2>a</h2><h2>b</h2>`,`<hr/><h2>a</h2><hr/><h2>b</h2>`},
+		{"InsertAfter",InsertAfter("h2",`<hr/>`),`<h2>a</h2><p>t</p>`,`<h2>a</h2><hr/><p>t</p>`},
+		{"Wrap",Wrap("img",`<figure class="f"></figure>`),`<img src="a"/><img src="b"/>`,`<figure class="f"><img src="a"/></figure><figure class="f"><img src="b"/></figure>`},
+		{"Chain",Chain(Remove("script"),Unwrap("font")),`<font>a<script>x</script></font>`,`a`},
+	}
+	for _,tt := range tests {
+		b := body(t,tt.in)
+		Walk(b,tt.tf)
+		if got := render(b); got!=tt.want { t.Errorf("%s:\n got %s\nwant %s",tt.name,got,tt.want) }
+	}
+}
+
+// Walk must visit every remaining node, even if fn removes the node it is called with.
+func TestWalkMutation(t *testing.T) {
+	b := body(t,`<p>1</p><p>2</p><p>3</p><div><p>4</p></div>`)
+	var seen []string
+	Walk(b,func(h *html.Node) {
+		if h.Type==html.TextNode { seen = append(seen,h.Data) }
+		if Match(h,"p") { Detach(h) }
+	})
+	if len(seen)!=0 { t.Errorf("visited the children of removed nodes: %v",seen) }
+	if got := render(b); got!=`<div></div>` { t.Errorf("got %s",got) }
+
+	// inserted nodes are not visited.
+	b = body(t,`<p>a</p>`)
+	n := 0
+	Walk(b,func(h *html.Node) {
+		if Match(h,"p") {
+			n++
+			InsertAfter("p",`<p>b</p>`)(h)
+		}
+	})
+	if n!=1 { t.Errorf("visited %d <p>, want 1",n) }
+}
+
+func TestMatchAndFindAll(t *testing.T) {
+	b := body(t,`<div id="a" class="x y"><p class="y">1</p><p>2</p></div>`)
+	if l := FindAll(b,".y"); len(l)!=2 { t.Errorf(".y: got %d, want 2",len(l)) }
+	if l := FindAll(b,"p"); len(l)!=2 { t.Errorf("p: got %d, want 2",len(l)) }
+	if n := LurkFor(b,"#a"); n==nil || Attr(n,"class")!="x y" { t.Errorf("#a: got %v",n) }
+	if Match(b.FirstChild.FirstChild.FirstChild,"*") { t.Errorf("a text node matches *") }
+}
+
+func TestClone(t *testing.T) {
+	b := body(t,`<p class="a">x<b>y</b></p>`)
+	c := Clone(b.FirstChild)
+	SetAttr("p","class","b")(c)
+	Detach(c.FirstChild)
+	if got := render(b); got!=`<p class="a">x<b>y</b></p>` { t.Errorf("original modified: %s",got) }
+}
@@ -0,0 +1,189 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package htmlscrape
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Attributes, that contain a single URL.
+var urlAttrs = map[string]bool{
+	"href":true,
+	"src":true,
+	"action":true,
+	"formaction":true,
+	"poster":true,
+	"cite":true,
+	"background":true,
+	"longdesc":true,
+	"usemap":true,
+	"data":true,
+	"codebase":true,
+	"manifest":true,
+	"icon":true,
+}
+
+// Reports, whether the attribute name (lower case) contains a single URL, eg. href or src.
+func IsURLAttr(name string) bool { return urlAttrs[name] }
+
+var cssUrl = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)`)
+
+func replaceCss(s string,f func(string)string) string {
+	return cssUrl.ReplaceAllStringFunc(s,func(m string) string{
+		sm := cssUrl.FindStringSubmatch(m)
+		u := sm[1]+sm[2]+sm[3]
+		if u=="" { return m }
+		return `url("`+strings.Replace(f(u),`"`,`%22`,-1)+`")`
+	})
+}
+
+func replaceSrcset(s string,f func(string)string) string {
+	buf := new(strings.Builder)
+	for {
+		s = strings.TrimLeft(s," \t\n\r\f,")
+		if s=="" { break }
+		i := strings.IndexAny(s," \t\n\r\f")
+		if i<0 { i = len(s) }
+		u := s[:i]
+		s = s[i:]
+		desc := ""
+		if strings.HasSuffix(u,",") {
+			u = strings.TrimRight(u,",")
+		} else {
+			i = strings.IndexByte(s,',')
+			if i<0 { i = len(s) }
+			desc = strings.TrimSpace(s[:i])
+			s = s[i:]
+		}
+		if buf.Len()>0 { buf.WriteString(", ") }
+		buf.WriteString(f(u))
+		if desc!="" { buf.WriteString(" "+desc) }
+	}
+	return buf.String()
+}
+
+/*
+ Like ReplaceHref, but passes every URL-bearing attribute (href, src, action,
+ poster, ...) through f. The candidates of srcset attributes, the url(...)
+ references within style attributes and the content of <style> elements are
+ rewritten as well.
+ */
+func ReplaceURLs(f func(string)string) Transf{
+	return func(h *html.Node){
+		switch h.Type {
+		case html.ElementNode:
+		case html.TextNode:
+			if h.Parent!=nil && h.Parent.DataAtom==atom.Style { h.Data = replaceCss(h.Data,f) }
+			return
+		default: return
+		}
+		for i,attr := range h.Attr {
+			if attr.Namespace!="" { continue }
+			switch {
+			case urlAttrs[attr.Key]:
+				h.Attr[i].Val = f(strings.TrimSpace(attr.Val))
+			case attr.Key=="srcset":
+				h.Attr[i].Val = replaceSrcset(attr.Val,f)
+			case attr.Key=="style":
+				h.Attr[i].Val = replaceCss(attr.Val,f)
+			}
+		}
+	}
+}
+
+func findBase(begin, end *html.Node) *html.Node{
+	if begin==nil { return nil }
+	for {
+		switch begin.Type {
+		case html.ElementNode:
+			if begin.DataAtom==atom.Base && findAttr(begin,"href")!="" { return begin }
+			fallthrough
+		case html.DocumentNode:
+			res := findBase(begin.FirstChild,begin.LastChild)
+			if res!=nil { return res }
+		}
+		if end==begin { return nil }
+		begin = begin.NextSibling
+	}
+}
+
+/*
+ Returns the base URL of a document, that has been retrieved from u. If the
+ document contains a <base href="..."> element, it is resolved against u and
+ returned, otherwise u itself is returned.
+ */
+func BaseURL(doc *html.Node,u *url.URL) *url.URL{
+	b := findBase(doc,doc)
+	if b==nil { return u }
+	bu,e := url.Parse(strings.TrimSpace(findAttr(b,"href")))
+	if e!=nil { return u }
+	if u==nil { return bu }
+	return u.ResolveReference(bu)
+}
+
+// Returns a function, that resolves URLs against base.
+func Resolver(base *url.URL) func(string)string{
+	return func(s string) string{
+		if s=="" || s[0]=='#' { return s }
+		u,e := url.Parse(s)
+		if e!=nil { return s }
+		switch u.Scheme {
+		case "data","javascript","mailto","tel","about": return s
+		}
+		return base.ResolveReference(u).String()
+	}
+}
+
+// Resolves every URL-bearing attribute against base.
+func Absolutize(base *url.URL) Transf{
+	return ReplaceURLs(Resolver(base))
+}
+
+/*
+ Resolves every URL within the document against its base URL (see BaseURL).
+ The <base> element is removed afterwards, because it would be meaningless
+ or even harmful, once the URLs are absolute.
+ */
+func AbsolutizeDoc(doc *html.Node,u *url.URL){
+	Walk(doc,Absolutize(BaseURL(doc,u)))
+	for b := findBase(doc,doc); b!=nil; b = findBase(doc,doc) {
+		b.Parent.RemoveChild(b)
+	}
+}
+
+/*
+ Returns a function, that maps absolute http(s) URLs to the URL of a local
+ proxy, mounted at prefix (eg. "/proxy/"). The original URL is passed as
+ "url" query parameter. All other URLs are returned as they are.
+
+ See webscrape.Proxy for a suitable http.Handler.
+ */
+func ProxyURL(prefix string) func(string)string{
+	return func(s string) string{
+		u,e := url.Parse(s)
+		if e!=nil { return s }
+		switch u.Scheme {
+		case "http","https":
+			return prefix+"?url="+url.QueryEscape(s)
+		}
+		return s
+	}
+}
@@ -0,0 +1,109 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ */
+
+package htmlscrape
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// parses src as the content of a <body>, which is returned.
+func body(t *testing.T,src string) *html.Node {
+	t.Helper()
+	b := &html.Node{Type:html.ElementNode,Data:"body",DataAtom:atom.Body}
+	nn,e := html.ParseFragment(strings.NewReader(src),b)
+	if e!=nil { t.Fatal(e) }
+	for _,n := range nn { b.AppendChild(n) }
+	return b
+}
+
+// parses src as a complete document.
+func doc(t *testing.T,src string) *html.Node {
+	t.Helper()
+	d,e := html.Parse(strings.NewReader(src))
+	if e!=nil { t.Fatal(e) }
+	return d
+}
+
+// renders the children of n.
+func render(n *html.Node) string {
+	buf := new(bytes.Buffer)
+	Render(buf,n)
+	return buf.String()
+}
+
+func mustURL(t *testing.T,s string) *url.URL {
+	t.Helper()
+	u,e := url.Parse(s)
+	if e!=nil { t.Fatal(e) }
+	return u
+}
+
+func TestAbsolutize(t *testing.T) {
+	base := mustURL(t,"http://example.com/dir/page.html")
+	tests := []struct{ in,want string }{
+		{`<a href="x.html">x</a>`,`<a href="http://example.com/dir/x.html">x</a>`},
+		{`<img src="/i.png"/>`,`<img src="http://example.com/i.png"/>`},
+		{`<form action="../post"></form>`,`<form action="http://example.com/post"></form>`},
+		{`<video poster="p.jpg"></video>`,`<video poster="http://example.com/dir/p.jpg"></video>`},
+		{`<img srcset="a.png 1x, b.png 2x"/>`,`<img srcset="http://example.com/dir/a.png 1x, http://example.com/dir/b.png 2x"/>`},
+		{`<div style="background: url('bg.png')"></div>`,`<div style="background: url(&#34;http://example.com/dir/bg.png&#34;)"></div>`},
+		{`<a href="#top">t</a>`,`<a href="#top">t</a>`},
+		{`<a href="mailto:a@b.c">m</a>`,`<a href="mailto:a@b.c">m</a>`},
+		{`<a href="//cdn.example.org/x">c</a>`,`<a href="http://cdn.example.org/x">c</a>`},
+	}
+	for _,tt := range tests {
+		b := body(t,tt.in)
+		Walk(b,Absolutize(base))
+		if got := render(b); got!=tt.want { t.Errorf("%s:\n got %s\nwant %s",tt.in,got,tt.want) }
+	}
+}
+
+func TestAbsolutizeStyleElement(t *testing.T) {
+	d := doc(t,`<html><head><style>body{background:url(bg.png)}</style></head><body></body></html>`)
+	Walk(d,Absolutize(mustURL(t,"http://example.com/a/")))
+	s := new(bytes.Buffer)
+	html.Render(s,d)
+	if !strings.Contains(s.String(),`url("http://example.com/a/bg.png")`) { t.Errorf("style not rewritten: %s",s) }
+}
+
+func TestBaseURL(t *testing.T) {
+	u := mustURL(t,"http://example.com/dir/page.html")
+	if b := BaseURL(doc(t,`<p>no base</p>`),u); b.String()!=u.String() { t.Errorf("got %v, want %v",b,u) }
+	d := doc(t,`<head><base href="/other/"></head><body><a href="x">x</a></body>`)
+	if b := BaseURL(d,u); b.String()!="http://example.com/other/" { t.Errorf("got %v",b) }
+	AbsolutizeDoc(d,u)
+	s := new(bytes.Buffer)
+	html.Render(s,d)
+	if !strings.Contains(s.String(),`href="http://example.com/other/x"`) { t.Errorf("not resolved against <base>: %s",s) }
+	if strings.Contains(s.String(),"<base") { t.Errorf("<base> not removed: %s",s) }
+}
+
+func TestProxyURL(t *testing.T) {
+	f := ProxyURL("/proxy/")
+	if got := f("http://example.com/a?b=c"); got!="/proxy/?url=http%3A%2F%2Fexample.com%2Fa%3Fb%3Dc" { t.Errorf("got %s",got) }
+	for _,s := range []string{"/local","data:text/plain,x","#frag"} {
+		if got := f(s); got!=s { t.Errorf("%s: got %s",s,got) }
+	}
+	b := body(t,`<img src="i.png"/>`)
+	Walk(b,ReplaceURLs(func(s string) string { return f(Resolver(mustURL(t,"https://example.com/"))(s)) }))
+	if got := render(b); got!=`<img src="/proxy/?url=https%3A%2F%2Fexample.com%2Fi.png"/>` { t.Errorf("got %s",got) }
+}
@@ -0,0 +1,140 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tmplhelp
+
+import (
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// decodes r into v. Unknown fields are errors.
+type decoder func(r io.Reader,v interface{}) error
+
+func decodeJson(r io.Reader,v interface{}) error {
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	if e := d.Decode(v); e!=nil { return e }
+	if d.More() { return errors.New("unexpected data after the JSON value") }
+	return nil
+}
+
+func decodeYaml(r io.Reader,v interface{}) error {
+	d := yaml.NewDecoder(r)
+	d.KnownFields(true)
+	e := d.Decode(v)
+	if e==io.EOF { return nil } // an empty file
+	return e
+}
+
+func decodeToml(r io.Reader,v interface{}) error {
+	return toml.NewDecoder(r).DisallowUnknownFields().Decode(v)
+}
+
+// the decoders by file extension.
+var decoders = map[string]decoder{
+	".json": decodeJson,
+	".yaml": decodeYaml,
+	".yml":  decodeYaml,
+	".toml": decodeToml,
+}
+
+// decodes the file name into v.
+func decodeFile(fsys fs.FS,name string,v interface{}) error {
+	dec,ok := decoders[strings.ToLower(path.Ext(name))]
+	if !ok { return fmt.Errorf("tmplhelp: %s: unknown data format",name) }
+	f,e := fsys.Open(name)
+	if e!=nil { return e }
+	defer f.Close()
+	if e = dec(f,v); e!=nil { return fmt.Errorf("tmplhelp: %s: %w",name,e) }
+	return nil
+}
+
+/*
+ Loads the data files names (JSON, YAML or TOML, by extension) into a T. The
+ decoding is strict: Fields, that T does not have, are errors.
+
+ Each file is decoded on top of the previous ones, so later files override
+ the values, they set: Struct fields and map keys, that a later file does not
+ mention, are kept; Slices are replaced. This requires a struct or map T, an
+ interface{} is replaced as a whole. Use FS to load from an http.FileSystem.
+
+	type Site struct{ Title string; Nav []Link }
+	site,e := tmplhelp.LoadData[Site](fsys,"site.yaml")
+ */
+func LoadData[T any](fsys fs.FS,names ...string) (T,error) {
+	var v T
+	if len(names)==0 { return v,errors.New("tmplhelp: no data file given") }
+	for _,n := range names {
+		if e := decodeFile(fsys,n,&v); e!=nil { return v,e }
+	}
+	return v,nil
+}
+
+/*
+ Returns the name of the overlay of name for the environment env, eg.
+ "site.prod.json" for "site.json" and "prod".
+ */
+func EnvName(name,env string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name,ext)+"."+env+ext
+}
+
+/*
+ Like LoadData, but each file is followed by its overlay for the environment
+ env (see EnvName), if it exists. If env is empty, no overlays are loaded.
+
+	site,e := tmplhelp.LoadDataEnv[Site](fsys,os.Getenv("SITE_ENV"),"site.json")
+ */
+func LoadDataEnv[T any](fsys fs.FS,env string,names ...string) (T,error) {
+	var l []string
+	for _,n := range names {
+		l = append(l,n)
+		if env=="" { continue }
+		o := EnvName(n,env)
+		if _,e := fs.Stat(fsys,o); e==nil {
+			l = append(l,o)
+		} else if !errors.Is(e,fs.ErrNotExist) {
+			var v T
+			return v,e
+		}
+	}
+	return LoadData[T](fsys,l...)
+}
+
+/*
+ Returns the template function "data", that loads a data file from fsys, eg.
+ {{range (data "nav.yaml").links}}. The file is loaded on every call and
+ decoded generically (maps, slices and scalars). Install it together with
+ Funcs:
+
+	l.Funcs = tmplhelp.Funcs()
+	maps.Copy(l.Funcs,tmplhelp.DataFuncs(fsys))
+ */
+func DataFuncs(fsys fs.FS) map[string]interface{} {
+	return map[string]interface{}{
+		"data": func(name string) (interface{},error) {
+			return LoadData[interface{}](fsys,name)
+		},
+	}
+}
@@ -0,0 +1,87 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tmplhelp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"text/template"
+)
+
+type link struct{ Title,URL string }
+
+type site struct{
+	Title string
+	Nav   []link
+	Meta  map[string]string
+}
+
+var dataFS = fstest.MapFS{
+	"site.json":      {Data:[]byte(`{"Title":"Site","Nav":[{"Title":"Home","URL":"/"}],"Meta":{"a":"1","b":"2"}}`)},
+	"site.prod.json": {Data:[]byte(`{"Title":"Prod","Meta":{"b":"3"}}`)},
+	"site.yaml":      {Data:[]byte("title: YAML\nnav:\n  - title: About\n    url: /about\n")},
+	"site.toml":      {Data:[]byte("Title = \"TOML\"\n[Meta]\nc = \"4\"\n")},
+	"empty.yaml":     {Data:[]byte("")},
+	"unknown.json":   {Data:[]byte(`{"Titel":"typo"}`)},
+	"unknown.yaml":   {Data:[]byte("titel: typo\n")},
+	"unknown.toml":   {Data:[]byte("Titel = \"typo\"\n")},
+	"trailing.json":  {Data:[]byte(`{"Title":"a"} {"Title":"b"}`)},
+	"site.txt":       {Data:[]byte(`Title`)},
+	"nav.yaml":       {Data:[]byte("links:\n  - a\n  - b\n")},
+}
+
+func TestLoadData(t *testing.T) {
+	s,e := LoadData[site](dataFS,"site.json","site.prod.json")
+	if e!=nil { t.Fatal(e) }
+	want := site{Title:"Prod",Nav:[]link{{"Home","/"}},Meta:map[string]string{"a":"1","b":"3"}}
+	if !reflect.DeepEqual(s,want) { t.Errorf("got %+v, want %+v",s,want) }
+
+	s,e = LoadData[site](dataFS,"site.json","site.yaml","site.toml","empty.yaml")
+	if e!=nil { t.Fatal(e) }
+	want = site{Title:"TOML",Nav:[]link{{"About","/about"}},Meta:map[string]string{"a":"1","b":"2","c":"4"}}
+	if !reflect.DeepEqual(s,want) { t.Errorf("got %+v, want %+v",s,want) }
+
+	for _,n := range []string{"unknown.json","unknown.yaml","unknown.toml","trailing.json","site.txt","missing.json"} {
+		if _,e := LoadData[site](dataFS,n); e==nil { t.Errorf("%s: no error",n) }
+	}
+	if _,e := LoadData[site](dataFS); e==nil { t.Error("no error without files") }
+	if _,e := LoadData[site](dataFS,"unknown.json"); e==nil || !strings.Contains(e.Error(),"unknown.json") { t.Errorf("the error does not name the file: %v",e) }
+}
+
+func TestLoadDataEnv(t *testing.T) {
+	if n := EnvName("conf/site.json","prod"); n!="conf/site.prod.json" { t.Errorf("EnvName = %s",n) }
+	for env,title := range map[string]string{"":"Site","prod":"Prod","dev":"Site"} {
+		s,e := LoadDataEnv[site](dataFS,env,"site.json")
+		if e!=nil { t.Fatal(e) }
+		if s.Title!=title { t.Errorf("%q: Title = %s, want %s",env,s.Title,title) }
+	}
+}
+
+func TestDataFuncs(t *testing.T) {
+	tpl := `{{range (data "nav.yaml").links}}[{{.}}]{{end}}`
+	funcs := DataFuncs(dataFS)
+	buf := new(strings.Builder)
+	if e := templateWith(tpl,funcs).Execute(buf,nil); e!=nil { t.Fatal(e) }
+	if buf.String()!="[a][b]" { t.Errorf("got %s",buf) }
+	if e := templateWith(`{{data "missing.yaml"}}`,funcs).Execute(buf,nil); e==nil { t.Error("no error") }
+}
+
+func templateWith(src string,funcs map[string]interface{}) *template.Template {
+	return template.Must(template.New("").Funcs(funcs).Parse(src))
+}
@@ -0,0 +1,147 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tmplhelp
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"slices"
+)
+
+// adapts an http.FileSystem to fs.FS.
+type httpFS struct{
+	fs http.FileSystem
+}
+
+type httpFile struct{
+	http.File
+}
+
+func (f httpFile) ReadDir(n int) ([]fs.DirEntry,error) {
+	fis,e := f.Readdir(n)
+	l := make([]fs.DirEntry,len(fis))
+	for i,fi := range fis { l[i] = fs.FileInfoToDirEntry(fi) }
+	return l,e
+}
+
+func (h httpFS) Open(name string) (fs.File,error) {
+	if !fs.ValidPath(name) { return nil,&fs.PathError{Op:"open",Path:name,Err:fs.ErrInvalid} }
+	f,e := h.fs.Open("/"+name)
+	if e!=nil { return nil,e }
+	return httpFile{f},nil
+}
+
+// reads the file n of the http.FileSystem hfs.
+func readFile(hfs http.FileSystem,n string) ([]byte,error) {
+	f,e := hfs.Open(n)
+	if e!=nil { return nil,e }
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// Returns an fs.FS, that reads from the http.FileSystem hfs (the reverse of http.FS).
+func FS(hfs http.FileSystem) fs.FS {
+	return httpFS{hfs}
+}
+
+/*
+ Returns an fs.FS, that consists of the layers: A file is opened from the
+ first layer, that has it, and directory listings are merged. This way a local
+ directory can override an embedded copy during development:
+
+	//go:embed web
+	var web embed.FS
+
+	sub,_ := fs.Sub(web,"web")
+	fsys := tmplhelp.Overlay(os.DirFS("web"),sub)
+
+ If the local directory does not exist, the embedded copy is used alone.
+ */
+func Overlay(layers ...fs.FS) fs.FS {
+	return overlayFS(layers)
+}
+
+type overlayFS []fs.FS
+
+func (o overlayFS) Open(name string) (fs.File,error) {
+	if !fs.ValidPath(name) { return nil,&fs.PathError{Op:"open",Path:name,Err:fs.ErrInvalid} }
+	for i,l := range o {
+		f,e := l.Open(name)
+		if errors.Is(e,fs.ErrNotExist) { continue }
+		if e!=nil { return nil,e }
+		fi,e := f.Stat()
+		if e==nil && fi.IsDir() && i+1<len(o) { return &overlayDir{File:f,fs:o,name:name},nil }
+		return f,nil
+	}
+	return nil,&fs.PathError{Op:"open",Path:name,Err:fs.ErrNotExist}
+}
+
+// merges the entries of the directory name in all layers, the first layer wins.
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry,error) {
+	var l []fs.DirEntry
+	seen := make(map[string]bool)
+	found := false
+	for _,ly := range o {
+		es,e := fs.ReadDir(ly,name)
+		if errors.Is(e,fs.ErrNotExist) { continue }
+		if e!=nil { return nil,e }
+		found = true
+		for _,de := range es {
+			if seen[de.Name()] { continue }
+			seen[de.Name()] = true
+			l = append(l,de)
+		}
+	}
+	if !found { return nil,&fs.PathError{Op:"readdir",Path:name,Err:fs.ErrNotExist} }
+	slices.SortFunc(l,func(a,b fs.DirEntry) int {
+		switch {
+		case a.Name()<b.Name(): return -1
+		case a.Name()>b.Name(): return 1
+		}
+		return 0
+	})
+	return l,nil
+}
+
+// a directory of an overlayFS, whose ReadDir lists the merged entries.
+type overlayDir struct{
+	fs.File
+	fs   overlayFS
+	name string
+	l    []fs.DirEntry
+	read bool
+}
+
+func (d *overlayDir) ReadDir(n int) ([]fs.DirEntry,error) {
+	if !d.read {
+		l,e := d.fs.ReadDir(d.name)
+		if e!=nil { return nil,e }
+		d.l,d.read = l,true
+	}
+	if n<=0 {
+		l := d.l
+		d.l = nil
+		return l,nil
+	}
+	if len(d.l)==0 { return nil,io.EOF }
+	n = min(n,len(d.l))
+	l := d.l[:n]
+	d.l = d.l[n:]
+	return l,nil
+}
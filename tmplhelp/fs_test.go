@@ -0,0 +1,116 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tmplhelp
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOverlay(t *testing.T) {
+	local := fstest.MapFS{
+		"web/a.html":     {Data:[]byte("local a")},
+		"web/new/c.html": {Data:[]byte("local c")},
+	}
+	embedded := fstest.MapFS{
+		"web/a.html":   {Data:[]byte("embedded a")},
+		"web/b.html":   {Data:[]byte("embedded b")},
+		"web/new":      {Data:[]byte("a file")},
+		"other/d.html": {Data:[]byte("embedded d")},
+	}
+	o := Overlay(local,embedded)
+	for n,want := range map[string]string{"web/a.html":"local a","web/b.html":"embedded b","web/new/c.html":"local c","other/d.html":"embedded d"} {
+		b,e := fs.ReadFile(o,n)
+		if e!=nil || string(b)!=want { t.Errorf("%s: got %q, %v, want %q",n,b,e,want) }
+	}
+	if _,e := o.Open("web/missing.html"); !errors.Is(e,fs.ErrNotExist) { t.Errorf("missing: %v",e) }
+	if _,e := o.Open("/web/a.html"); e==nil { t.Error("opened an invalid path") }
+
+	es,e := fs.ReadDir(o,"web")
+	if e!=nil { t.Fatal(e) }
+	var names []string
+	for _,de := range es { names = append(names,de.Name()+map[bool]string{true:"/"}[de.IsDir()]) }
+	if !reflect.DeepEqual(names,[]string{"a.html","b.html","new/"}) { t.Errorf("ReadDir = %v",names) }
+
+	m,e := fs.Glob(o,"*/*.html")
+	if e!=nil || !reflect.DeepEqual(m,[]string{"other/d.html","web/a.html","web/b.html"}) { t.Errorf("Glob = %v, %v",m,e) }
+
+	if e = fstest.TestFS(Overlay(fstest.MapFS{"x/1.txt":{Data:[]byte("1")}},fstest.MapFS{"x/2.txt":{Data:[]byte("2")}}),"x/1.txt","x/2.txt"); e!=nil { t.Error(e) }
+}
+
+var loadFS = fstest.MapFS{
+	"page.tmpl": {Data:[]byte(`{{. | upper}}`)},
+	"doc.json":  {Data:[]byte(`{"a":[1,"b"]}`)},
+}
+
+func TestLoaders(t *testing.T) {
+	hfs := http.FS(loadFS)
+	if e := fstest.TestFS(FS(hfs),"page.tmpl","doc.json"); e!=nil { t.Error(e) }
+
+	for _,n := range []string{"/page.tmpl","page.tmpl"} {
+		buf := new(bytes.Buffer)
+		tt,e := LoadTemplate(hfs,n,Funcs())
+		if e!=nil { t.Fatal(e) }
+		tt.Execute(buf,"<b>")
+		th,e := LoadHTMLTemplate(hfs,n,Funcs())
+		if e!=nil { t.Fatal(e) }
+		th.Execute(buf,"<b>")
+		if buf.String()!="<B>&lt;B&gt;" { t.Errorf("%s: got %s",n,buf) }
+	}
+	if _,e := LoadTemplate(hfs,"/missing.tmpl"); e==nil { t.Error("loaded a missing template") }
+	if _,e := LoadHTMLTemplateFS(loadFS,"missing.tmpl"); e==nil { t.Error("loaded a missing template") }
+
+	want := map[string]interface{}{"a":[]interface{}{1.0,"b"}}
+	for _,load := range []func() (interface{},error){
+		func() (interface{},error) { return LoadJson(hfs,"/doc.json") },
+		func() (interface{},error) { return LoadJsonFS(loadFS,"doc.json") },
+	} {
+		v,e := load()
+		if e!=nil || !reflect.DeepEqual(v,want) { t.Errorf("got %v, %v",v,e) }
+	}
+}
+
+func TestLoadersHTTPNames(t *testing.T) {
+	dir := t.TempDir()
+	if e := os.Mkdir(filepath.Join(dir,"dir"),0755); e!=nil { t.Fatal(e) }
+	write(t,filepath.Join(dir,"dir","page.tmpl"),`{{.}}`)
+	write(t,filepath.Join(dir,"doc.json"),`[1]`)
+	hfs := http.Dir(dir)
+
+	tt,e := LoadTemplate(hfs,"/dir/page.tmpl")
+	if e!=nil || tt.Name()!="/dir/page.tmpl" { t.Fatalf("got %v, %v",tt,e) }
+	th,e := LoadHTMLTemplate(hfs,"/dir/page.tmpl")
+	if e!=nil || th.Name()!="/dir/page.tmpl" { t.Fatalf("got %v, %v",th,e) }
+	for _,n := range []string{"./dir/page.tmpl","/a/../dir/page.tmpl","dir/page.tmpl"} {
+		if _,e := LoadTemplate(hfs,n); e!=nil { t.Errorf("LoadTemplate(%q): %v",n,e) }
+		if _,e := LoadHTMLTemplate(hfs,n); e!=nil { t.Errorf("LoadHTMLTemplate(%q): %v",n,e) }
+	}
+	for _,n := range []string{"/doc.json","./doc.json","/a/../doc.json"} {
+		if v,e := LoadJson(hfs,n); e!=nil || !reflect.DeepEqual(v,[]interface{}{1.0}) { t.Errorf("LoadJson(%q): %v, %v",n,v,e) }
+	}
+	r,e := ReloadTemplate(hfs,"./dir/../dir/page.tmpl")
+	if e!=nil { t.Fatal(e) }
+	r.Close()
+	if fsName("./dir/../dir/page.tmpl")!="dir/page.tmpl" || fsName("/doc.json")!="doc.json" { t.Error("fsName") }
+}
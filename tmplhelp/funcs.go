@@ -0,0 +1,335 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tmplhelp
+
+import (
+	"github.com/maxymania/scrapland/htmlscrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	htmltemplate "html/template"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+ Returns a new map of the standard template functions. The piped value is
+ always the last argument, eg. {{.Title | truncate 20}}. Install it with the
+ funcs argument of LoadTemplate or LoadHTMLTemplate, or with Layouts.Funcs.
+
+ Strings:
+	lower, upper, title, trim, trimPrefix p, trimSuffix s, replace old new,
+	contains sub, hasPrefix p, hasSuffix s, split sep, join sep, repeat n,
+	truncate n (appends "…"), pathEscape, queryEscape
+ Math (int64, or float64 if an argument is a float):
+	add, sub, mul, div, mod, max, min
+ Dates (time.Time, *time.Time, Unix seconds or RFC 3339 strings):
+	now, date layout, unix
+ Data:
+	dict k v ..., list v ..., default d v, coalesce v ..., empty v, toJson v
+ Safe HTML (html/template only):
+	safeURL u (unsafe schemes become "about:invalid"), attr name value
+ Scraped fragments (string, html/template.HTML or *html.Node):
+	text f, select sel f, absolutize base f
+ The results keep the type of f, but a *html.Node yields html/template.HTML,
+ that is sanitized (see htmlscrape.Sanitize).
+ */
+func Funcs() map[string]interface{} {
+	return map[string]interface{}{
+		"lower":       strings.ToLower,
+		"upper":       strings.ToUpper,
+		"title":       title,
+		"trim":        strings.TrimSpace,
+		"trimPrefix":  func(p,s string) string { return strings.TrimPrefix(s,p) },
+		"trimSuffix":  func(x,s string) string { return strings.TrimSuffix(s,x) },
+		"replace":     func(o,n,s string) string { return strings.ReplaceAll(s,o,n) },
+		"contains":    func(sub,s string) bool { return strings.Contains(s,sub) },
+		"hasPrefix":   func(p,s string) bool { return strings.HasPrefix(s,p) },
+		"hasSuffix":   func(x,s string) bool { return strings.HasSuffix(s,x) },
+		"split":       func(sep,s string) []string { return strings.Split(s,sep) },
+		"join":        join,
+		"repeat":      func(n int,s string) string { return strings.Repeat(s,n) },
+		"truncate":    truncate,
+		"pathEscape":  url.PathEscape,
+		"queryEscape": url.QueryEscape,
+
+		"add": arith(func(a,b int64) (int64,error) { return a+b,nil },func(a,b float64) float64 { return a+b }),
+		"sub": arith(func(a,b int64) (int64,error) { return a-b,nil },func(a,b float64) float64 { return a-b }),
+		"mul": arith(func(a,b int64) (int64,error) { return a*b,nil },func(a,b float64) float64 { return a*b }),
+		"div": arith(func(a,b int64) (int64,error) {
+			if b==0 { return 0,errDivZero }
+			return a/b,nil
+		},func(a,b float64) float64 { return a/b }),
+		"mod": arith(func(a,b int64) (int64,error) {
+			if b==0 { return 0,errDivZero }
+			return a%b,nil
+		},nil),
+		"max": arith(func(a,b int64) (int64,error) { return max(a,b),nil },func(a,b float64) float64 { return max(a,b) }),
+		"min": arith(func(a,b int64) (int64,error) { return min(a,b),nil },func(a,b float64) float64 { return min(a,b) }),
+
+		"now":  time.Now,
+		"date": date,
+		"unix": func(t interface{}) (int64,error) {
+			tt,e := toTime(t)
+			return tt.Unix(),e
+		},
+
+		"dict":     dict,
+		"list":     func(v ...interface{}) []interface{} { return v },
+		"default":  func(d,v interface{}) interface{} {
+			if empty(v) { return d }
+			return v
+		},
+		"coalesce": func(v ...interface{}) interface{} {
+			for _,x := range v {
+				if !empty(x) { return x }
+			}
+			return nil
+		},
+		"empty":    empty,
+		"toJson":   func(v interface{}) (string,error) {
+			b,e := json.Marshal(v)
+			return string(b),e
+		},
+
+		"safeURL": safeURL,
+		"attr":    attr,
+
+		"text":       text,
+		"select":     selectFrag,
+		"absolutize": absolutize,
+	}
+}
+
+var errDivZero = errors.New("tmplhelp: division by zero")
+
+func title(s string) string {
+	b := []byte(s)
+	up := true
+	for i,c := range b {
+		if up && c>='a' && c<='z' { b[i] = c-'a'+'A' }
+		up = c==' ' || c=='\t' || c=='\n' || c=='-'
+	}
+	return string(b)
+}
+
+// joins the elements of any slice, using fmt.Sprint.
+func join(sep string,l interface{}) (string,error) {
+	v := reflect.ValueOf(l)
+	if v.Kind()!=reflect.Slice && v.Kind()!=reflect.Array { return "",fmt.Errorf("tmplhelp: join: %T is not a list",l) }
+	s := make([]string,v.Len())
+	for i := range s { s[i] = fmt.Sprint(v.Index(i).Interface()) }
+	return strings.Join(s,sep),nil
+}
+
+// shortens s to n runes (including the "…").
+func truncate(n int,s string) string {
+	if n<1 || utf8.RuneCountInString(s)<=n { return s }
+	r := []rune(s)
+	return strings.TrimRight(string(r[:n-1])," \t\n")+"…"
+}
+
+// converts v into an int64 or, if it is a float or a string containing one, a float64.
+func number(v interface{}) (int64,float64,bool,error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int,reflect.Int8,reflect.Int16,reflect.Int32,reflect.Int64:
+		return rv.Int(),0,false,nil
+	case reflect.Uint,reflect.Uint8,reflect.Uint16,reflect.Uint32,reflect.Uint64,reflect.Uintptr:
+		return int64(rv.Uint()),0,false,nil
+	case reflect.Float32,reflect.Float64:
+		return 0,rv.Float(),true,nil
+	case reflect.String:
+		if i,e := strconv.ParseInt(rv.String(),10,64); e==nil { return i,0,false,nil }
+		f,e := strconv.ParseFloat(rv.String(),64)
+		if e==nil { return 0,f,true,nil }
+	}
+	return 0,0,false,fmt.Errorf("tmplhelp: %v is not a number",v)
+}
+
+// folds the arguments with fi, or with ff, as soon as a float is involved.
+func arith(fi func(a,b int64) (int64,error),ff func(a,b float64) float64) func(a interface{},b ...interface{}) (interface{},error) {
+	return func(a interface{},b ...interface{}) (interface{},error) {
+		i,f,isf,e := number(a)
+		if e!=nil { return nil,e }
+		for _,x := range b {
+			xi,xf,xisf,e := number(x)
+			if e!=nil { return nil,e }
+			if (isf || xisf) && ff==nil { return nil,errors.New("tmplhelp: integer operation on float") }
+			switch {
+			case isf && xisf: f = ff(f,xf)
+			case isf:         f = ff(f,float64(xi))
+			case xisf:        f,isf = ff(float64(i),xf),true
+			default:
+				i,e = fi(i,xi)
+				if e!=nil { return nil,e }
+			}
+		}
+		if isf { return f,nil }
+		return i,nil
+	}
+}
+
+func toTime(t interface{}) (time.Time,error) {
+	switch v := t.(type) {
+	case time.Time: return v,nil
+	case *time.Time:
+		if v!=nil { return *v,nil }
+	case string:
+		return time.Parse(time.RFC3339,v)
+	default:
+		if i,_,isf,e := number(t); e==nil && !isf { return time.Unix(i,0),nil }
+	}
+	return time.Time{},fmt.Errorf("tmplhelp: %v is not a time",t)
+}
+
+// formats t with the layout of package time, eg. "2006-01-02".
+func date(layout string,t interface{}) (string,error) {
+	tt,e := toTime(t)
+	if e!=nil { return "",e }
+	return tt.Format(layout),nil
+}
+
+func dict(kv ...interface{}) (map[string]interface{},error) {
+	if len(kv)%2!=0 { return nil,errors.New("tmplhelp: dict needs key/value pairs") }
+	m := make(map[string]interface{},len(kv)/2)
+	for i := 0; i<len(kv); i+=2 {
+		k,ok := kv[i].(string)
+		if !ok { return nil,fmt.Errorf("tmplhelp: dict key %v is not a string",kv[i]) }
+		m[k] = kv[i+1]
+	}
+	return m,nil
+}
+
+// reports, whether v is nil, false, 0, "" or an empty collection.
+func empty(v interface{}) bool {
+	if v==nil { return true }
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Array,reflect.Map,reflect.Slice,reflect.String,reflect.Chan:
+		return rv.Len()==0
+	case reflect.Pointer,reflect.Interface:
+		return rv.IsNil()
+	}
+	return rv.IsZero()
+}
+
+// the URL schemes, safeURL lets through.
+var safeSchemes = map[string]bool{"http":true,"https":true,"mailto":true,"tel":true}
+
+// marks u as safe for URL attributes, if it is relative or uses a safe scheme.
+func safeURL(u string) htmltemplate.URL {
+	p,e := url.Parse(strings.TrimSpace(u))
+	if e!=nil || (p.Scheme!="" && !safeSchemes[strings.ToLower(p.Scheme)]) { return "about:invalid" }
+	return htmltemplate.URL(u)
+}
+
+var attrName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_:-]*$`)
+
+/*
+ Returns the attribute name="value" (escaped), eg. <a {{attr "title" .T}}>.
+ Event handlers and style are refused, URLs (also namespaced ones, like
+ xlink:href) are checked with safeURL.
+ */
+func attr(name,value string) (htmltemplate.HTMLAttr,error) {
+	ln := strings.ToLower(name)
+	// the local name of a namespaced attribute, eg. "href" of "xlink:href".
+	local := ln[strings.LastIndexByte(ln,':')+1:]
+	if !attrName.MatchString(name) || strings.HasPrefix(local,"on") || local=="style" || local=="srcdoc" {
+		return "",fmt.Errorf("tmplhelp: attr: unsafe attribute %q",name)
+	}
+	if htmlscrape.IsURLAttr(ln) || htmlscrape.IsURLAttr(local) { value = string(safeURL(value)) }
+	return htmltemplate.HTMLAttr(name+`="`+html.EscapeString(value)+`"`),nil
+}
+
+/*
+ parses a fragment in the context of <body> and returns its top-level nodes.
+ A *html.Node is cloned, so that it can be modified safely.
+ */
+func fragment(f interface{}) ([]*html.Node,error) {
+	var s string
+	switch v := f.(type) {
+	case *html.Node:
+		return []*html.Node{htmlscrape.Clone(v)},nil
+	case htmltemplate.HTML: s = string(v)
+	case string:            s = v
+	case fmt.Stringer:      s = v.String()
+	default:
+		return nil,fmt.Errorf("tmplhelp: %T is not an HTML fragment",f)
+	}
+	body := &html.Node{Type:html.ElementNode,Data:"body",DataAtom:atom.Body}
+	return html.ParseFragment(strings.NewReader(s),body)
+}
+
+/*
+ renders nodes, keeping the type of the fragment f (html/template.HTML or
+ string). The nodes of a *html.Node are sanitized and html/template.HTML.
+ */
+func asFragment(f interface{},nodes []*html.Node) (interface{},error) {
+	_,isNode := f.(*html.Node)
+	if isNode {
+		body := &html.Node{Type:html.ElementNode,Data:"body",DataAtom:atom.Body}
+		for _,n := range nodes { body.AppendChild(htmlscrape.Clone(n)) }
+		htmlscrape.Walk(body,htmlscrape.Sanitize)
+		nodes = nil
+		for c := body.FirstChild; c!=nil; c = c.NextSibling { nodes = append(nodes,c) }
+	}
+	buf := new(bytes.Buffer)
+	for _,n := range nodes {
+		if e := html.Render(buf,n); e!=nil { return nil,e }
+	}
+	if _,ok := f.(htmltemplate.HTML); ok || isNode { return htmltemplate.HTML(buf.String()),nil }
+	return buf.String(),nil
+}
+
+// the text of the fragment f (see htmlscrape.Text), escaped, if f is a *html.Node.
+func text(f interface{}) (interface{},error) {
+	nn,e := fragment(f)
+	if e!=nil { return nil,e }
+	var s string
+	for _,n := range nn { s += htmlscrape.Text(n,nil) }
+	s = strings.TrimSpace(s)
+	if _,ok := f.(*html.Node); ok { return htmltemplate.HTML(html.EscapeString(s)),nil }
+	return s,nil
+}
+
+// the elements of f, that match sel (see htmlscrape.Match).
+func selectFrag(sel string,f interface{}) (interface{},error) {
+	nn,e := fragment(f)
+	if e!=nil { return nil,e }
+	var l []*html.Node
+	for _,n := range nn { l = append(l,htmlscrape.FindAll(n,sel)...) }
+	return asFragment(f,l)
+}
+
+// resolves the URLs within f against base.
+func absolutize(base string,f interface{}) (interface{},error) {
+	b,e := url.Parse(base)
+	if e!=nil { return nil,e }
+	nn,e := fragment(f)
+	if e!=nil { return nil,e }
+	for _,n := range nn { htmlscrape.Walk(n,htmlscrape.Absolutize(b)) }
+	return asFragment(f,nn)
+}
@@ -0,0 +1,121 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tmplhelp
+
+import (
+	"github.com/maxymania/scrapland/htmlscrape"
+	"golang.org/x/net/html"
+	htmltemplate "html/template"
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+// executes src as an html/template with Funcs.
+func runHTML(src string,data interface{}) (string,error) {
+	t,e := htmltemplate.New("").Funcs(Funcs()).Parse(src)
+	if e!=nil { return "",e }
+	buf := new(bytes.Buffer)
+	e = t.Execute(buf,data)
+	return buf.String(),e
+}
+
+// executes src as a text/template with Funcs.
+func runText(src string,data interface{}) (string,error) {
+	t,e := template.New("").Funcs(Funcs()).Parse(src)
+	if e!=nil { return "",e }
+	buf := new(bytes.Buffer)
+	e = t.Execute(buf,data)
+	return buf.String(),e
+}
+
+func TestFuncs(t *testing.T) {
+	tm := time.Date(2015,3,4,5,6,7,0,time.UTC)
+	for _,c := range []struct{ src,want string; data interface{} }{
+		{`{{"hello world-wide" | title}}`,"Hello World-Wide",nil},
+		{`{{"a b" | replace " " "_" | upper}}`,"A_B",nil},
+		{`{{"x.html" | trimSuffix ".html"}} {{"/a" | trimPrefix "/"}}`,"x a",nil},
+		{`{{"a,b" | split "," | join "+"}}`,"a+b",nil},
+		{`{{. | join ", "}}`,"1, 2",[]int{1,2}},
+		{`{{"Hello World" | truncate 7}}`,"Hello…",nil},
+		{`{{"äöü" | truncate 3}}`,"äöü",nil},
+		{`{{"a b" | queryEscape}}`,"a+b",nil},
+		{`{{add 1 2 3}} {{sub 5 2}} {{mul 2 1.5}} {{div 7 2}} {{mod 7 2}}`,"6 3 3 3 1",nil},
+		{`{{max 1 "4" 2}} {{min 1.5 2}}`,"4 1.5",nil},
+		{`{{date "2006-01-02" .}}`,"2015-03-04",tm},
+		{`{{date "15:04" "2015-03-04T05:06:07Z"}} {{unix .}}`,"05:06 1425445567",tm},
+		{`{{(dict "a" 1 "b" .).b}}`,"x","x"},
+		{`{{default "d" .}} {{default "d" "v"}} {{coalesce "" 0 "c"}}`,"d v c",""},
+		{`{{empty .}}`,"true",map[string]int{}},
+		{`{{empty 0}} {{empty "x"}} {{empty (list)}}`,"true false true",nil},
+		{`{{toJson .}}`,`{"a":[1,2]}`,map[string][]int{"a":{1,2}}},
+	} {
+		got,e := runText(c.src,c.data)
+		if e!=nil || got!=c.want { t.Errorf("%s: got %q, %v, want %q",c.src,got,e,c.want) }
+	}
+	for _,src := range []string{`{{div 1 0}}`,`{{mod 1 0}}`,`{{mod 1.5 1}}`,`{{add 1 "x"}}`,`{{dict "a"}}`,`{{dict 1 2}}`,`{{date "2006" "yesterday"}}`,`{{join "," 1}}`} {
+		if _,e := runText(src,nil); e==nil { t.Errorf("%s: no error",src) }
+	}
+}
+
+func TestSafeHTMLFuncs(t *testing.T) {
+	for _,c := range []struct{ src,want string }{
+		{`<a href="{{safeURL "https://x/?a=1&b=2"}}">`,`<a href="https://x/?a=1&amp;b=2">`},
+		{`<a href="{{safeURL "javascript:alert(1)"}}">`,`<a href="about:invalid">`},
+		{`<a {{attr "title" "a\"b"}}>`,`<a title="a&#34;b">`},
+		{`<a {{attr "HREF" "javascript:x"}}>`,`<a HREF="about:invalid">`},
+		{`<img {{attr "poster" "vbscript:x"}}>`,`<img poster="about:invalid">`},
+		{`<img {{attr "longdesc" "javascript:x"}}>`,`<img longdesc="about:invalid">`},
+		{`<svg><a {{attr "xlink:href" "javascript:x"}}>`,`<svg><a xlink:href="about:invalid">`},
+		{`<svg><use {{attr "XLink:Href" "/s.svg#i"}}>`,`<svg><use XLink:Href="/s.svg#i">`},
+	} {
+		got,e := runHTML(c.src,nil)
+		if e!=nil || got!=c.want { t.Errorf("%s: got %q, %v, want %q",c.src,got,e,c.want) }
+	}
+	for _,name := range []string{"onclick","style","srcdoc","a b","xlink:onclick","x:style"} {
+		if _,e := runHTML(`<a {{attr .  "x"}}>`,name); e==nil { t.Errorf("attr %q: no error",name) }
+	}
+}
+
+func TestFragmentFuncs(t *testing.T) {
+	const frag = `<p class="x">One &amp; <b>two</b></p><script>alert(1)</script><p onclick="evil()"><a href="/a">three</a></p>`
+	doc,e := html.Parse(strings.NewReader("<body>"+frag))
+	if e!=nil { t.Fatal(e) }
+	body := htmlscrape.FindAll(doc,"body")[0]
+
+	for _,c := range []struct{ src,want string; data interface{} }{
+		// strings are escaped by html/template.
+		{`{{select "b" .}}`,`&lt;b&gt;two&lt;/b&gt;`,frag},
+		{`{{text .}}`,`One &amp; twothree`,frag},
+		// html/template.HTML is trusted.
+		{`{{select "p" .}}`,`<p class="x">One &amp; <b>two</b></p><p onclick="evil()"><a href="/a">three</a></p>`,htmltemplate.HTML(frag)},
+		{`{{absolutize "http://h/" . | select "a"}}`,`<a href="http://h/a">three</a>`,htmltemplate.HTML(frag)},
+		// a *html.Node is sanitized.
+		{`{{select "p" .}}`,`<p class="x">One &amp; <b>two</b></p><p><a href="/a">three</a></p>`,body},
+		{`{{select "script" .}}`,``,body},
+		{`{{absolutize "http://h/" .}}`,`<body><p class="x">One &amp; <b>two</b></p><p><a href="http://h/a">three</a></p></body>`,body},
+		{`{{text .}}`,"One &amp; two\n\nthree",body},
+	} {
+		got,e := runHTML(c.src,c.data)
+		if e!=nil || got!=c.want { t.Errorf("%s (%T): got %q, %v, want %q",c.src,c.data,got,e,c.want) }
+	}
+	if got,e := runText(`{{select ".x" .}}`,frag); e!=nil || got!=`<p class="x">One &amp; <b>two</b></p>` { t.Errorf("got %q, %v",got,e) }
+	if htmlscrape.FindAll(body,"script")==nil { t.Error("the node was modified") }
+	if _,e := runHTML(`{{text .}}`,42); e==nil { t.Error("no error for a number") }
+}
@@ -0,0 +1,48 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tmplhelp
+
+import (
+	"io/fs"
+	"net/http"
+	"html/template"
+)
+
+/*
+ Loads an html/template from a http.FileSystem object. Unlike LoadTemplate,
+ the content is escaped according to its context (use it together with
+ container.HTMLContainer). The funcs are installed before parsing.
+ */
+func LoadHTMLTemplate(fs http.FileSystem,n string,funcs ...map[string]interface{}) (*template.Template,error) {
+	b,e := readFile(fs,n)
+	if e!=nil { return nil,e }
+	return parseHTMLTemplate(n,b,funcs)
+}
+
+// parses the html/template source b, named n.
+func parseHTMLTemplate(n string,b []byte,funcs []map[string]interface{}) (*template.Template,error) {
+	t := template.New(n)
+	for _,fm := range funcs { t.Funcs(fm) }
+	return t.Parse(string(b))
+}
+
+// Like LoadHTMLTemplate, but loads the template from an fs.FS (eg. an embed.FS or Overlay).
+func LoadHTMLTemplateFS(fsys fs.FS,n string,funcs ...map[string]interface{}) (*template.Template,error) {
+	b,e := fs.ReadFile(fsys,n)
+	if e!=nil { return nil,e }
+	return parseHTMLTemplate(n,b,funcs)
+}
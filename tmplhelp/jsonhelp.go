@@ -17,16 +17,31 @@
 package tmplhelp
 
 import (
+	"io"
+	"io/fs"
 	"net/http"
 	"encoding/json"
 )
 
 // Loads a json-document from a http.FileSystem object
 func LoadJson(fs http.FileSystem,n string) (interface{},error) {
-	var i interface{} = nil
 	f,e := fs.Open(n)
 	if e!=nil { return nil,e }
-	e = json.NewDecoder(f).Decode(&i)
-	f.Close()
+	defer f.Close()
+	return decodeDocument(f)
+}
+
+// decodes the JSON document r generically (maps, slices and scalars).
+func decodeDocument(r io.Reader) (interface{},error) {
+	var i interface{} = nil
+	e := json.NewDecoder(r).Decode(&i)
 	return i,e
 }
+
+// Like LoadJson, but loads the document from an fs.FS (eg. an embed.FS or Overlay).
+func LoadJsonFS(fsys fs.FS,n string) (interface{},error) {
+	f,e := fsys.Open(n)
+	if e!=nil { return nil,e }
+	defer f.Close()
+	return decodeDocument(f)
+}
@@ -0,0 +1,167 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tmplhelp
+
+import (
+	"github.com/maxymania/scrapland/container"
+	htmltemplate "html/template"
+	"text/template"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Returned by LayoutSet.Execute, as a LayoutSet needs a page name.
+var ErrNoPage = errors.New("tmplhelp: no page selected")
+
+// A parsed template, either a text/template or an html/template.
+type Template = container.Templates
+
+/*
+ Describes a set of templates, that consists of layouts, partials and pages.
+
+ The layouts and partials are parsed into a common base. Each page is
+ parsed into its own copy of the base, so it can override the {{block}}s
+ of the layouts with {{define}}. A page picks its layout by defining the
+ template "layout", eg. {{define "layout"}}wide.html{{end}}; Otherwise
+ DefaultLayout is used.
+
+	layouts/base.html:    <html>{{block "main" .}}{{end}}{{template "footer.html" .}}</html>
+	partials/footer.html: <footer>...</footer>
+	pages/index.html:     {{define "main"}}<h1>{{.Title.Get}}</h1>{{end}}
+ */
+type Layouts struct{
+	// The glob patterns (see fs.Glob) of the layouts, the partials and the pages.
+	Layouts  []string
+	Partials []string
+	Pages    []string
+
+	// The layout of pages, that do not pick one. If empty, the first layout
+	// is used.
+	DefaultLayout string
+
+	// If true, html/template is used instead of text/template.
+	HTML     bool
+
+	// Functions available in all templates, eg. Funcs().
+	Funcs    map[string]interface{}
+}
+
+/*
+ A parsed Layouts set. The pages are selected by their file name, eg.
+ "index.html". It can be passed to container.NewContainer (or to
+ NewHTMLContainer, if Layouts.HTML is set); The Page selects the page with
+ its Template field.
+ */
+type LayoutSet struct{
+	pages map[string]Template
+	html  bool
+}
+
+// Reports, whether the pages are html/templates (see container.IsHTML).
+func (s *LayoutSet) HTML() bool { return s.html }
+
+// Returns the template of the page name (ready to Execute), or nil.
+func (s *LayoutSet) Lookup(name string) Template {
+	return s.pages[name]
+}
+
+// Returns the names of the pages, sorted.
+func (s *LayoutSet) Pages() []string {
+	l := make([]string,0,len(s.pages))
+	for n := range s.pages { l = append(l,n) }
+	slices.Sort(l)
+	return l
+}
+
+// Always fails with ErrNoPage, use ExecuteTemplate.
+func (s *LayoutSet) Execute(w io.Writer,data interface{}) error { return ErrNoPage }
+
+// Executes the page name with its layout.
+func (s *LayoutSet) ExecuteTemplate(w io.Writer,name string,data interface{}) error {
+	t,ok := s.pages[name]
+	if !ok { return fmt.Errorf("tmplhelp: no page %q",name) }
+	return t.Execute(w,data)
+}
+
+func glob(fsys fs.FS,patterns []string) ([]string,error) {
+	var l []string
+	for _,p := range patterns {
+		m,e := fs.Glob(fsys,p)
+		if e!=nil { return nil,e }
+		l = append(l,m...)
+	}
+	return l,nil
+}
+
+// the layout picked by the page t.
+func pickLayout(t Template,def string) string {
+	buf := new(bytes.Buffer)
+	if t.ExecuteTemplate(buf,"layout",nil)!=nil { return def }
+	if s := strings.TrimSpace(buf.String()); s!="" { return s }
+	return def
+}
+
+// Parses the set from fsys. Use FS to load from an http.FileSystem.
+func (l *Layouts) Load(fsys fs.FS) (*LayoutSet,error) {
+	layouts,e := glob(fsys,l.Layouts)
+	if e!=nil { return nil,e }
+	if len(layouts)==0 { return nil,errors.New("tmplhelp: no layouts found") }
+	partials,e := glob(fsys,l.Partials)
+	if e!=nil { return nil,e }
+	pages,e := glob(fsys,l.Pages)
+	if e!=nil { return nil,e }
+	def := l.DefaultLayout
+	if def=="" { def = path.Base(layouts[0]) }
+	base := slices.Concat(layouts,partials)
+
+	s := &LayoutSet{pages:make(map[string]Template),html:l.HTML}
+	for _,p := range pages {
+		name := path.Base(p)
+		if _,dup := s.pages[name]; dup { return nil,fmt.Errorf("tmplhelp: duplicate page %q",name) }
+		var t Template
+		if l.HTML {
+			t,e = l.parseHTML(fsys,base,p,def)
+		} else {
+			t,e = l.parseText(fsys,base,p,def)
+		}
+		if e!=nil { return nil,e }
+		s.pages[name] = t
+	}
+	return s,nil
+}
+
+func (l *Layouts) parseText(fsys fs.FS,base []string,page,def string) (Template,error) {
+	t,e := template.New(path.Base(base[0])).Funcs(l.Funcs).ParseFS(fsys,slices.Concat(base,[]string{page})...)
+	if e!=nil { return nil,e }
+	lt := t.Lookup(pickLayout(t,def))
+	if lt==nil { return nil,fmt.Errorf("tmplhelp: %s: no layout %q",page,pickLayout(t,def)) }
+	return lt,nil
+}
+
+func (l *Layouts) parseHTML(fsys fs.FS,base []string,page,def string) (Template,error) {
+	t,e := htmltemplate.New(path.Base(base[0])).Funcs(l.Funcs).ParseFS(fsys,slices.Concat(base,[]string{page})...)
+	if e!=nil { return nil,e }
+	lt := t.Lookup(pickLayout(t,def))
+	if lt==nil { return nil,fmt.Errorf("tmplhelp: %s: no layout %q",page,pickLayout(t,def)) }
+	return lt,nil
+}
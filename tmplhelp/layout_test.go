@@ -0,0 +1,111 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tmplhelp
+
+import (
+	"github.com/maxymania/scrapland/container"
+	htmltemplate "html/template"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"text/template"
+)
+
+var layoutFS = fstest.MapFS{
+	"layouts/base.html":    {Data:[]byte(`<main>{{block "main" .}}default{{end}}</main>{{template "footer.html" .}}`)},
+	"layouts/wide.html":    {Data:[]byte(`<div class="wide">{{block "main" .}}{{end}}</div>`)},
+	"partials/footer.html": {Data:[]byte(`<footer>{{.}}</footer>`)},
+	"pages/index.html":     {Data:[]byte(`{{define "main"}}<h1>{{.}}</h1>{{end}}`)},
+	"pages/about.html":     {Data:[]byte(`{{define "layout"}}wide.html{{end}}{{define "main"}}about {{.}}{{end}}`)},
+	"pages/empty.html":     {Data:[]byte(``)},
+}
+
+func layouts(html bool) *Layouts {
+	return &Layouts{Layouts:[]string{"layouts/*.html"},Partials:[]string{"partials/*.html"},Pages:[]string{"pages/*.html"},HTML:html}
+}
+
+func execute(t *testing.T,tpl Template,name string,data interface{}) string {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	if e := tpl.ExecuteTemplate(buf,name,data); e!=nil { t.Fatal(e) }
+	return buf.String()
+}
+
+func TestLayouts(t *testing.T) {
+	s,e := layouts(false).Load(layoutFS)
+	if e!=nil { t.Fatal(e) }
+	if got := s.Pages(); !reflect.DeepEqual(got,[]string{"about.html","empty.html","index.html"}) { t.Errorf("Pages = %v",got) }
+	for name,want := range map[string]string{
+		"index.html":`<main><h1><b></h1></main><footer><b></footer>`,
+		"about.html":`<div class="wide">about <b></div>`,
+		"empty.html":`<main>default</main><footer><b></footer>`,
+	} {
+		if got := execute(t,s,name,"<b>"); got!=want { t.Errorf("%s: got %s, want %s",name,got,want) }
+	}
+	if e = s.Execute(new(bytes.Buffer),nil); e!=ErrNoPage { t.Errorf("Execute: %v",e) }
+	if e = s.ExecuteTemplate(new(bytes.Buffer),"missing.html",nil); e==nil { t.Errorf("executed a missing page") }
+	if s.Lookup("index.html")==nil || s.Lookup("missing.html")!=nil { t.Errorf("Lookup failed") }
+	if s.HTML() || container.IsHTML(s) { t.Errorf("a text/template set reports HTML") }
+
+	s,e = layouts(true).Load(layoutFS)
+	if e!=nil { t.Fatal(e) }
+	if got := execute(t,s,"index.html","<b>"); got!=`<main><h1>&lt;b&gt;</h1></main><footer>&lt;b&gt;</footer>` { t.Errorf("got %s",got) }
+	if !container.IsHTML(s) { t.Errorf("an html/template set does not report HTML") }
+
+	l := layouts(false)
+	l.DefaultLayout = "missing.html"
+	if _,e = l.Load(layoutFS); e==nil { t.Errorf("loaded with a missing layout") }
+	l = layouts(false)
+	l.Layouts = []string{"none/*.html"}
+	if _,e = l.Load(layoutFS); e==nil { t.Errorf("loaded without layouts") }
+	l = layouts(false)
+	l.Pages = []string{"pages/*.html","pages/index.html"}
+	if _,e = l.Load(layoutFS); e==nil || !strings.Contains(e.Error(),"duplicate") { t.Errorf("got %v",e) }
+}
+
+func TestHTMLContainerTemplates(t *testing.T) {
+	pg := container.HTMLPageGenFunc(func(r *http.Request) (*container.HTMLPage,error) {
+		return &container.HTMLPage{Title:container.NewElement().Offer("<i>"),Template:"index.html"},nil
+	})
+	s,e := layouts(true).Load(layoutFS)
+	if e!=nil { t.Fatal(e) }
+	w := httptest.NewRecorder()
+	container.NewHTMLContainer(s,pg).ServeHTTP(w,httptest.NewRequest("GET","/",nil))
+	if !strings.Contains(w.Body.String(),"<h1>{") { t.Errorf("got %s",w.Body) }
+
+	for _,tpl := range []container.Templates{
+		template.Must(template.New("").Parse(`x`)),
+		must(layouts(false).Load(layoutFS)),
+	} {
+		func(){
+			defer func(){
+				if recover()==nil { t.Errorf("%T was accepted",tpl) }
+			}()
+			container.NewHTMLContainer(tpl,pg)
+		}()
+	}
+	container.NewHTMLContainer(htmltemplate.Must(htmltemplate.New("").Parse(`x`)),pg)
+}
+
+func must[T any](v T,e error) T {
+	if e!=nil { panic(e) }
+	return v
+}
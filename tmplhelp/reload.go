@@ -0,0 +1,304 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tmplhelp
+
+import (
+	"github.com/maxymania/scrapland/container"
+	"github.com/fsnotify/fsnotify"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reports changes of a source of files.
+type Watcher interface{
+	// Receives a value after every change (several changes may be coalesced).
+	// It is closed, after the Watcher is closed.
+	Changes() <-chan struct{}
+	Close() error
+}
+
+// notifies ch without blocking.
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+type poller struct{
+	ch   chan struct{}
+	stop chan struct{}
+	once sync.Once
+}
+
+func (p *poller) Changes() <-chan struct{} { return p.ch }
+func (p *poller) Close() error {
+	p.once.Do(func(){ close(p.stop) })
+	return nil
+}
+
+// the modification times and sizes of the files matching the patterns.
+func snapshot(fsys fs.FS,patterns []string) string {
+	var s string
+	for _,pt := range patterns {
+		m,_ := fs.Glob(fsys,pt)
+		for _,n := range m {
+			fi,e := fs.Stat(fsys,n)
+			if e!=nil { continue }
+			s += fmt.Sprintf("%s %d %d\n",n,fi.ModTime().UnixNano(),fi.Size())
+		}
+	}
+	return s
+}
+
+/*
+ Returns a Watcher, that checks the modification times of the files matching
+ the glob patterns (see fs.Glob) every interval. Added and removed files are
+ detected as well. It works with any fs.FS (use FS for an http.FileSystem).
+ */
+func Poll(fsys fs.FS,interval time.Duration,patterns ...string) Watcher {
+	p := &poller{ch:make(chan struct{},1),stop:make(chan struct{})}
+	last := snapshot(fsys,patterns)
+	go func(){
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		defer close(p.ch)
+		for {
+			select {
+			case <- t.C:
+			case <- p.stop:
+				return
+			}
+			if s := snapshot(fsys,patterns); s!=last {
+				last = s
+				notify(p.ch)
+			}
+		}
+	}()
+	return p
+}
+
+type notifier struct{
+	w  *fsnotify.Watcher
+	ch chan struct{}
+}
+
+func (n *notifier) Changes() <-chan struct{} { return n.ch }
+func (n *notifier) Close() error { return n.w.Close() }
+
+/*
+ Returns a Watcher, that uses the notifications of the operating system
+ (inotify on Linux) for the local directory dir and its subdirectories.
+ */
+func Notify(dir string) (Watcher,error) {
+	w,e := fsnotify.NewWatcher()
+	if e!=nil { return nil,e }
+	e = filepath.WalkDir(dir,func(p string,d fs.DirEntry,err error) error {
+		if err!=nil { return err }
+		if d.IsDir() { return w.Add(p) }
+		return nil
+	})
+	if e!=nil {
+		w.Close()
+		return nil,e
+	}
+	n := &notifier{w,make(chan struct{},1)}
+	go func(){
+		defer close(n.ch)
+		for {
+			select {
+			case ev,ok := <- w.Events:
+				if !ok { return }
+				if ev.Has(fsnotify.Create) {
+					if fi,e := os.Stat(ev.Name); e==nil && fi.IsDir() { w.Add(ev.Name) }
+				}
+				if ev.Op!=fsnotify.Chmod { notify(n.ch) }
+			case _,ok := <- w.Errors:
+				if !ok { return }
+			}
+		}
+	}()
+	return n,nil
+}
+
+/*
+ A Reloader keeps a value, that is loaded from files (eg. a template), and
+ loads it again, when the Watcher reports a change. If the reload fails, the
+ last good value is kept and the error is reported.
+ */
+type Reloader[T any] struct{
+	load     func() (T,error)
+	w        Watcher
+	done     chan struct{}
+	once     sync.Once
+	mutex    sync.RWMutex
+	v        T
+	err      error
+	onError  func(error)
+	onReload func(T)
+}
+
+// the time, a reload is delayed to coalesce the events of a save.
+const reloadDelay = 50*time.Millisecond
+
+/*
+ Loads the value with load and reloads it, whenever w reports a change. If
+ the first load fails, w is closed and the error is returned.
+ */
+func NewReloader[T any](w Watcher,load func() (T,error)) (*Reloader[T],error) {
+	v,e := load()
+	if e!=nil {
+		w.Close()
+		return nil,e
+	}
+	r := &Reloader[T]{load:load,w:w,done:make(chan struct{}),v:v}
+	go func(){
+		for {
+			select {
+			case _,ok := <- w.Changes():
+				if !ok { return }
+			case <- r.done:
+				return
+			}
+			select {
+			case <- time.After(reloadDelay):
+			case <- r.done:
+				return
+			}
+			select {
+			case <- w.Changes():
+			default:
+			}
+			r.Reload()
+		}
+	}()
+	return r,nil
+}
+
+// Returns the current value.
+func (r *Reloader[T]) Get() T {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.v
+}
+
+// Returns the error of the last reload, nil if it succeeded.
+func (r *Reloader[T]) Err() error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.err
+}
+
+// Sets the function, that is called with the error of a failed reload. If nil, it is logged.
+func (r *Reloader[T]) OnError(f func(error)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.onError = f
+}
+
+// Sets the function, that is called after a successful reload (may be nil).
+func (r *Reloader[T]) OnReload(f func(T)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.onReload = f
+}
+
+// Loads the value again, keeping the old one, if it fails.
+func (r *Reloader[T]) Reload() error {
+	v,e := r.load()
+	r.mutex.Lock()
+	r.err = e
+	if e==nil { r.v = v }
+	onError,onReload := r.onError,r.onReload
+	r.mutex.Unlock()
+	switch {
+	case e!=nil && onError!=nil:
+		onError(e)
+	case e!=nil:
+		log.Printf("tmplhelp: reload failed: %v",e)
+	case onReload!=nil:
+		onReload(v)
+	}
+	return e
+}
+
+// Stops watching and reloading. The Reloader keeps its current value.
+func (r *Reloader[T]) Close() error {
+	r.once.Do(func(){ close(r.done) })
+	return r.w.Close()
+}
+
+/*
+ A Reloader of templates. It can be used in place of a *template.Template,
+ eg. in container.NewContainer.
+ */
+type TemplateReloader struct{
+	*Reloader[Template]
+}
+
+func (r TemplateReloader) Execute(w io.Writer,data interface{}) error {
+	return r.Get().Execute(w,data)
+}
+func (r TemplateReloader) ExecuteTemplate(w io.Writer,name string,data interface{}) error {
+	return r.Get().ExecuteTemplate(w,name,data)
+}
+
+// Reports, whether the template is an html/template (see container.IsHTML).
+func (r TemplateReloader) HTML() bool { return container.IsHTML(r.Get()) }
+
+// the default poll interval.
+const pollInterval = time.Second
+
+// Like LoadTemplate, but the template is reloaded, when the file changes (polling every second).
+func ReloadTemplate(hfs http.FileSystem,n string,funcs ...map[string]interface{}) (TemplateReloader,error) {
+	r,e := NewReloader(Poll(FS(hfs),pollInterval,fsName(n)),func() (Template,error) { return LoadTemplate(hfs,n,funcs...) })
+	return TemplateReloader{r},e
+}
+
+// Like LoadHTMLTemplate, but the template is reloaded, when the file changes (polling every second).
+func ReloadHTMLTemplate(hfs http.FileSystem,n string,funcs ...map[string]interface{}) (TemplateReloader,error) {
+	r,e := NewReloader(Poll(FS(hfs),pollInterval,fsName(n)),func() (Template,error) { return LoadHTMLTemplate(hfs,n,funcs...) })
+	return TemplateReloader{r},e
+}
+
+/*
+ Like Layouts.Load, but the set is reloaded, when w reports a change, eg.
+ Notify("templates") or Poll(fsys,time.Second,"*\/*.html").
+ */
+func (l *Layouts) Reload(fsys fs.FS,w Watcher) (TemplateReloader,error) {
+	r,e := NewReloader(w,func() (Template,error) { return l.Load(fsys) })
+	return TemplateReloader{r},e
+}
+
+// Like LoadJson, but the document is reloaded, when the file changes (polling every second).
+func ReloadJson(hfs http.FileSystem,n string) (*Reloader[interface{}],error) {
+	return NewReloader(Poll(FS(hfs),pollInterval,fsName(n)),func() (interface{},error) { return LoadJson(hfs,n) })
+}
+
+// the fs.FS name of the http.FileSystem name n (eg. "a/b.json" for "/x/../a/b.json").
+func fsName(n string) string {
+	return strings.TrimPrefix(path.Clean("/"+n),"/")
+}
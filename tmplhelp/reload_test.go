@@ -0,0 +1,147 @@
+/*
+   Copyright 2015 Simon Schmidt
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tmplhelp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// a Watcher, that reports the changes sent by the test.
+type testWatcher struct{
+	ch     chan struct{}
+	once   sync.Once
+	closed chan struct{}
+}
+
+func newTestWatcher() *testWatcher {
+	return &testWatcher{ch:make(chan struct{}),closed:make(chan struct{})}
+}
+func (w *testWatcher) Changes() <-chan struct{} { return w.ch }
+func (w *testWatcher) Close() error {
+	w.once.Do(func(){ close(w.closed) })
+	return nil
+}
+
+// waits for a value from ch or its closing and reports, whether it was closed.
+func receive(t *testing.T,ch <-chan struct{}) (closed bool) {
+	t.Helper()
+	select {
+	case _,ok := <- ch:
+		return !ok
+	case <- time.After(5*time.Second):
+		t.Fatal("timed out")
+	}
+	return
+}
+
+func TestReloader(t *testing.T) {
+	w := newTestWatcher()
+	var mutex sync.Mutex
+	v,fail := 1,error(nil)
+	r,e := NewReloader(w,func() (int,error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if fail!=nil { return 0,fail }
+		return v,nil
+	})
+	if e!=nil { t.Fatal(e) }
+	if r.Get()!=1 { t.Fatalf("Get = %d",r.Get()) }
+
+	reloaded := make(chan int,1)
+	failed := make(chan error,1)
+	r.OnReload(func(v int){ reloaded <- v })
+	r.OnError(func(e error){ failed <- e })
+
+	mutex.Lock(); v = 2; mutex.Unlock()
+	w.ch <- struct{}{}
+	if got := <- reloaded; got!=2 || r.Get()!=2 || r.Err()!=nil { t.Errorf("reloaded %d, Get = %d, Err = %v",got,r.Get(),r.Err()) }
+
+	mutex.Lock(); fail = errors.New("broken"); mutex.Unlock()
+	w.ch <- struct{}{}
+	if got := <- failed; got!=fail || r.Get()!=2 || r.Err()!=fail { t.Errorf("failed %v, Get = %d, Err = %v",got,r.Get(),r.Err()) }
+
+	r.Close()
+	if !receive(t,w.closed) { t.Fatal("the watcher was not closed") }
+	select {
+	case w.ch <- struct{}{}:
+		t.Error("the reloader still receives changes after Close")
+	case <- time.After(2*reloadDelay):
+	}
+}
+
+func TestReloaderFirstLoad(t *testing.T) {
+	w := newTestWatcher()
+	fail := errors.New("broken")
+	if _,e := NewReloader(w,func() (int,error) { return 0,fail }); e!=fail { t.Errorf("got %v",e) }
+	if !receive(t,w.closed) { t.Error("the watcher was not closed") }
+}
+
+func write(t *testing.T,name,data string) {
+	t.Helper()
+	if e := os.WriteFile(name,[]byte(data),0644); e!=nil { t.Fatal(e) }
+}
+
+func TestPoll(t *testing.T) {
+	dir := t.TempDir()
+	write(t,filepath.Join(dir,"a.html"),"a")
+	w := Poll(os.DirFS(dir),10*time.Millisecond,"*.html")
+	write(t,filepath.Join(dir,"b.html"),"b")
+	if receive(t,w.Changes()) { t.Fatal("closed") }
+	w.Close()
+	for !receive(t,w.Changes()) {}
+}
+
+func TestNotify(t *testing.T) {
+	dir := t.TempDir()
+	w,e := Notify(dir)
+	if e!=nil { t.Skip(e) }
+	write(t,filepath.Join(dir,"a.html"),"a")
+	if receive(t,w.Changes()) { t.Fatal("closed") }
+	w.Close()
+	for !receive(t,w.Changes()) {}
+}
+
+func TestLayoutsReload(t *testing.T) {
+	dir := t.TempDir()
+	for _,d := range []string{"layouts","pages"} {
+		if e := os.Mkdir(filepath.Join(dir,d),0755); e!=nil { t.Fatal(e) }
+	}
+	write(t,filepath.Join(dir,"layouts","base.html"),`[{{block "main" .}}{{end}}]`)
+	write(t,filepath.Join(dir,"pages","index.html"),`{{define "main"}}one{{end}}`)
+	l := &Layouts{Layouts:[]string{"layouts/*.html"},Pages:[]string{"pages/*.html"},HTML:true}
+	fsys := os.DirFS(dir)
+	r,e := l.Reload(fsys,Poll(fsys,10*time.Millisecond,"*/*.html"))
+	if e!=nil { t.Fatal(e) }
+	defer r.Close()
+	if got := execute(t,r,"index.html",nil); got!="[one]" { t.Errorf("got %s",got) }
+	if !r.HTML() { t.Error("not HTML") }
+
+	reloaded := make(chan Template,1)
+	r.OnReload(func(Template){ reloaded <- nil })
+	write(t,filepath.Join(dir,"pages","index.html"),`{{define "main"}}two, and more{{end}}`)
+	select {
+	case <- reloaded:
+	case <- time.After(5*time.Second):
+		t.Fatal("not reloaded")
+	}
+	if got := execute(t,r,"index.html",nil); got!="[two, and more]" { t.Errorf("got %s",got) }
+}
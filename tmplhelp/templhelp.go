@@ -16,25 +16,37 @@
 
 /*
  This package offers a convenient Helper for loading templates from an
- http.FileSystem object.
+ http.FileSystem object. The functions ending in FS, Layouts and LoadData
+ load from an fs.FS instead, such as an embed.FS or an Overlay.
  */
 package tmplhelp
 
 import (
+	"io/fs"
 	"net/http"
 	"text/template"
-	"bytes"
 )
 
-// Loads a template from a http.FileSystem object
-func LoadTemplate(fs http.FileSystem,n string) (*template.Template,error) {
-	f,e := fs.Open(n)
-	if e!=nil { return nil,e }
-	w := &bytes.Buffer{}
-	_,e = w.ReadFrom(f)
-	f.Close()
+/*
+ Loads a template from a http.FileSystem object. The funcs are installed
+ before parsing, eg. LoadTemplate(fs,"/page.tmpl",Funcs()).
+ */
+func LoadTemplate(fs http.FileSystem,n string,funcs ...map[string]interface{}) (*template.Template,error) {
+	b,e := readFile(fs,n)
 	if e!=nil { return nil,e }
-	return template.New(n).Parse(w.String())
+	return parseTemplate(n,b,funcs)
 }
 
+// parses the template source b, named n.
+func parseTemplate(n string,b []byte,funcs []map[string]interface{}) (*template.Template,error) {
+	t := template.New(n)
+	for _,fm := range funcs { t.Funcs(fm) }
+	return t.Parse(string(b))
+}
 
+// Like LoadTemplate, but loads the template from an fs.FS (eg. an embed.FS or Overlay).
+func LoadTemplateFS(fsys fs.FS,n string,funcs ...map[string]interface{}) (*template.Template,error) {
+	b,e := fs.ReadFile(fsys,n)
+	if e!=nil { return nil,e }
+	return parseTemplate(n,b,funcs)
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"github.com/maxymania/scrapland/htmlscrape"
+	"github.com/antchfx/xmlquery"
+	"github.com/jmespath/go-jmespath"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+/*
+ A parsed response body. A Document may be shared by concurrent callers
+ (see Fetcher), so Query must not modify it.
+ */
+type Document interface{
+	// Evaluates the Selectors of q and returns the content for q.Element.
+	Query(q *QueryElement) (string,error)
+}
+
+/*
+ A Parser parses a response body with the given Content-Type header, that
+ has been retrieved from u.
+ */
+type Parser func(body io.Reader, contentType string, u *url.URL) (Document,error)
+
+var parsers = struct{
+	sync.RWMutex
+	m map[string]Parser
+}{m:map[string]Parser{
+	"text/html":ParseHTML,
+	"application/xhtml+xml":ParseHTML,
+	"application/json":ParseJSON,
+	"text/json":ParseJSON,
+	"+json":ParseJSON,
+	"application/xml":ParseXML,
+	"text/xml":ParseXML,
+	"+xml":ParseXML,
+}}
+
+/*
+ Registers a Parser for a media type (eg. "application/json"). A media type
+ starting with "+" registers the Parser for a structured syntax suffix (eg.
+ "+json" for "application/ld+json").
+ */
+func RegisterParser(mediaType string, p Parser) {
+	parsers.Lock()
+	defer parsers.Unlock()
+	parsers.m[strings.ToLower(mediaType)] = p
+}
+
+/*
+ Parses a response body with the Parser, that is registered for its
+ Content-Type. Bodies without a Content-Type are parsed as HTML, for any
+ other type (including text types like text/plain) an *UnsupportedTypeError
+ is returned.
+ */
+func Parse(body io.Reader, contentType string, u *url.URL) (Document,error) {
+	mt,_,_ := mime.ParseMediaType(contentType)
+	parsers.RLock()
+	p,ok := parsers.m[mt]
+	if !ok {
+		if i := strings.LastIndexByte(mt,'+'); i>=0 { p,ok = parsers.m[mt[i:]] }
+	}
+	parsers.RUnlock()
+	if !ok {
+		if mt!="" { return nil,&UnsupportedTypeError{mt} }
+		p = ParseHTML
+	}
+	return p(body,contentType,u)
+}
+
+// the charset parameter of a Content-Type.
+func charsetOf(contentType string) string {
+	_,params,_ := mime.ParseMediaType(contentType)
+	return params["charset"]
+}
+
+// Implemented by the Documents returned by ParseHTML.
+type HTMLDocument interface{
+	Document
+
+	// The parsed document. It must not be modified.
+	Node() *html.Node
+
+	// The URL, that relative URLs are resolved against (see htmlscrape.BaseURL).
+	Base() *url.URL
+}
+
+type htmlDocument struct{
+	p    *html.Node
+	base *url.URL
+}
+
+func (d *htmlDocument) Node() *html.Node { return d.p }
+func (d *htmlDocument) Base() *url.URL { return d.base }
+
+/*
+ Parses an HTML document. The character encoding is determined by the BOM,
+ the charset in contentType or a <meta charset> element (in that order) and
+ the document is transcoded to UTF-8.
+ */
+func ParseHTML(body io.Reader, contentType string, u *url.URL) (Document,error) {
+	r,e := charset.NewReader(body,contentType)
+	if e!=nil { return nil,e }
+	p,e := html.Parse(r)
+	if e!=nil { return nil,e }
+	return &htmlDocument{p,htmlscrape.BaseURL(p,u)},nil
+}
+
+func (d *htmlDocument) Query(q *QueryElement) (string,error) {
+	qe := d.p
+	for _,s := range q.Selectors {
+		qe = htmlscrape.LurkFor(qe,s)
+	}
+	if q.Article && qe!=nil { qe = htmlscrape.FindContent(qe) }
+	if qe==nil { return "",ErrNotFound }
+	return renderHTML(q,htmlscrape.Clone(qe),d.base)
+}
+
+// renders the selected (and unshared) element qe for q.
+func renderHTML(q *QueryElement,qe *html.Node,base *url.URL) (string,error) {
+	if !q.Raw && base!=nil { htmlscrape.Walk(qe,htmlscrape.Absolutize(base)) }
+	if q.URLMap!=nil { htmlscrape.Walk(qe,htmlscrape.ReplaceURLs(q.URLMap)) }
+	for _,t := range q.Transforms { htmlscrape.Walk(qe,t) }
+	buf := &bytes.Buffer{}
+	if q.Tag=="" {
+		htmlscrape.Render(buf,qe)
+	} else if q.Tag=="-" {
+		html.Render(buf,qe)
+	} else if q.Tag==TagMarkdown {
+		md := ""
+		if q.Markdown!=nil {
+			md = q.Markdown.String(qe)
+		} else {
+			md = htmlscrape.ToMarkdown(qe)
+		}
+		if q.Trusted { return md,nil }
+		return html.EscapeString(md),nil
+	} else {
+		t := htmlscrape.Text(qe,q.Text)
+		return "<"+q.Tag+">"+html.EscapeString(t)+"</"+q.Tag+">",nil
+	}
+	return buf.String(),nil
+}
+
+type jsonDocument struct{
+	v interface{}
+}
+
+/*
+ Parses a JSON document. If contentType has a charset other than UTF-8,
+ the document is transcoded.
+
+ The Selectors are JSONPath expressions, if they start with "$" (see
+ JSONPath), JMESPath expressions otherwise. If Tag is empty, strings are
+ returned as they are and other values are JSON-encoded. As the values are
+ text, they are HTML-escaped, unless the QueryElement is Trusted. Otherwise
+ the value is rendered as text and enclosed in Tag.
+ */
+func ParseJSON(body io.Reader, contentType string, u *url.URL) (Document,error) {
+	if cs := charsetOf(contentType); cs!="" {
+		r,e := charset.NewReaderLabel(cs,body)
+		if e!=nil { return nil,e }
+		body = r
+	}
+	d := new(jsonDocument)
+	e := json.NewDecoder(body).Decode(&d.v)
+	if e!=nil { return nil,e }
+	return d,nil
+}
+
+func (d *jsonDocument) Query(q *QueryElement) (string,error) {
+	v := d.v
+	var e error
+	for _,s := range q.Selectors {
+		if strings.HasPrefix(s,"$") {
+			v,e = JSONPath(v,s)
+		} else {
+			v,e = jmespath.Search(s,v)
+		}
+		if e!=nil { return "",e }
+		if v==nil { return "",ErrNotFound }
+	}
+	s,ok := v.(string)
+	if !ok {
+		b,e := json.Marshal(v)
+		if e!=nil { return "",e }
+		s = string(b)
+	}
+	if q.Tag=="" || q.Tag=="-" {
+		if q.Trusted { return s,nil }
+		return html.EscapeString(s),nil
+	}
+	return "<"+q.Tag+">"+html.EscapeString(s)+"</"+q.Tag+">",nil
+}
+
+type xmlDocument struct{
+	n *xmlquery.Node
+}
+
+/*
+ Parses an XML document. The character encoding is taken from the charset
+ in contentType or, if there is none, from the XML declaration.
+
+ The Selectors are XPath expressions. If Tag is empty, the content of the
+ selected node is returned as XML, if Tag is "-", the node itself is
+ included. Otherwise the text of the node is enclosed in Tag.
+ */
+func ParseXML(body io.Reader, contentType string, u *url.URL) (Document,error) {
+	cs := charsetOf(contentType)
+	if cs=="" {
+		n,e := xmlquery.Parse(body)
+		if e!=nil { return nil,e }
+		return &xmlDocument{n},nil
+	}
+	r,e := charset.NewReaderLabel(cs,body)
+	if e!=nil { return nil,e }
+	// the body is UTF-8 now, whatever the XML declaration says.
+	n,e := xmlquery.ParseWithOptions(r,xmlquery.ParserOptions{Decoder:&xmlquery.DecoderOptions{
+		Strict: true,
+		CharsetReader: func(label string,input io.Reader) (io.Reader,error) { return input,nil },
+	}})
+	if e!=nil { return nil,e }
+	return &xmlDocument{n},nil
+}
+
+func (d *xmlDocument) Query(q *QueryElement) (string,error) {
+	n := d.n
+	for _,s := range q.Selectors {
+		qn,e := xmlquery.Query(n,s)
+		if e!=nil { return "",e }
+		if qn==nil { return "",ErrNotFound }
+		n = qn
+	}
+	switch q.Tag {
+	case "":
+		return n.OutputXML(false),nil
+	case "-":
+		return n.OutputXML(true),nil
+	}
+	return "<"+q.Tag+">"+html.EscapeString(n.InnerText())+"</"+q.Tag+">",nil
+}
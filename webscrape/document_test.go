@@ -0,0 +1,134 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T,body,contentType string) Document {
+	t.Helper()
+	u,_ := url.Parse("http://example.com/")
+	d,e := Parse(strings.NewReader(body),contentType,u)
+	if e!=nil { t.Fatal(e) }
+	return d
+}
+
+func mustQuery(t *testing.T,d Document,q *QueryElement) string {
+	t.Helper()
+	s,e := d.Query(q)
+	if e!=nil { t.Fatal(e) }
+	return s
+}
+
+func TestCharsets(t *testing.T) {
+	tests := []struct{ name,body,ct string }{
+		{"header","<p id=x>Gr\xfc\xdfe</p>","text/html; charset=ISO-8859-1"},
+		{"meta","<meta charset=windows-1252><p id=x>Gr\xfc\xdfe</p>","text/html"},
+		{"http-equiv",`<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1"><p id=x>Gr`+"\xfc\xdf"+`e</p>`,"text/html"},
+		{"BOM","\xef\xbb\xbf<p id=x>Grüße</p>","text/html; charset=ISO-8859-1"},
+		{"utf-8","<p id=x>Grüße</p>","text/html; charset=utf-8"},
+	}
+	for _,tt := range tests {
+		if got := mustQuery(t,parse(t,tt.body,tt.ct),&QueryElement{Selectors:[]string{"#x"}}); got!="Grüße" { t.Errorf("%s: got %q",tt.name,got) }
+	}
+	if got := mustQuery(t,parse(t,"<p id=x>\x82\xa0</p>","text/html; charset=Shift_JIS"),&QueryElement{Selectors:[]string{"#x"}}); got!="あ" {
+		t.Errorf("Shift_JIS: got %q",got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	d := parse(t,`{"title":"<b>x</b> & y","items":[{"n":1},{"n":2}],"obj":{"a":"b"}}`,"application/json")
+	tests := []struct{
+		q    *QueryElement
+		want string
+	}{
+		{&QueryElement{Selectors:[]string{"$.title"}},"&lt;b&gt;x&lt;/b&gt; &amp; y"},
+		{&QueryElement{Selectors:[]string{"$.title"},Trusted:true},"<b>x</b> & y"},
+		{&QueryElement{Selectors:[]string{"title"},Tag:"h1"},"<h1>&lt;b&gt;x&lt;/b&gt; &amp; y</h1>"},
+		{&QueryElement{Selectors:[]string{"$.items[*].n"}},"[1,2]"},
+		{&QueryElement{Selectors:[]string{"$..n"}},"[1,2]"},
+		{&QueryElement{Selectors:[]string{"$.items[-1]"},Trusted:true},`{"n":2}`},
+		{&QueryElement{Selectors:[]string{"items[0].n"}},"1"},
+		{&QueryElement{Selectors:[]string{"obj","a"}},"b"},
+	}
+	for _,tt := range tests {
+		if got := mustQuery(t,d,tt.q); got!=tt.want { t.Errorf("%v: got %q, want %q",tt.q.Selectors,got,tt.want) }
+	}
+	if _,e := d.Query(&QueryElement{Selectors:[]string{"$.missing"}}); !errors.Is(e,ErrNotFound) { t.Errorf("got %v, want ErrNotFound",e) }
+	if _,e := d.Query(&QueryElement{Selectors:[]string{"$["}}); e==nil { t.Errorf("no error for an invalid path") }
+
+	d = parse(t,`{"t":"Gr`+"\xfc\xdf"+`e"}`,"application/json; charset=iso-8859-1")
+	if got := mustQuery(t,d,&QueryElement{Selectors:[]string{"t"}}); got!="Grüße" { t.Errorf("charset: got %q",got) }
+	d = parse(t,`{"t":"v"}`,"application/ld+json")
+	if got := mustQuery(t,d,&QueryElement{Selectors:[]string{"t"}}); got!="v" { t.Errorf("+json: got %q",got) }
+}
+
+func TestJSONPath(t *testing.T) {
+	var v interface{} = map[string]interface{}{"a":[]interface{}{"x",map[string]interface{}{"b":"y"}}}
+	for path,want := range map[string]interface{}{
+		"$.a[0]":"x",
+		"$['a'][1].b":"y",
+		"$.a[5]":nil,
+		"$..b":[]interface{}{"y"},
+	} {
+		got,e := JSONPath(v,path)
+		if e!=nil || !reflect.DeepEqual(got,want) { t.Errorf("%s: got %v, %v, want %v",path,got,e,want) }
+	}
+	if _,e := JSONPath(v,"a"); !errors.Is(e,ErrJSONPath) { t.Errorf("got %v, want ErrJSONPath",e) }
+}
+
+func TestXML(t *testing.T) {
+	d := parse(t,`<?xml version="1.0"?><feed><entry><title>A &amp; B</title></entry><entry><title>C</title></entry></feed>`,"application/atom+xml")
+	if got := mustQuery(t,d,&QueryElement{Selectors:[]string{"//entry[2]/title"},Tag:"h2"}); got!="<h2>C</h2>" { t.Errorf("got %q",got) }
+	if got := mustQuery(t,d,&QueryElement{Selectors:[]string{"//entry[1]"},Tag:"-"}); got!="<entry><title>A &amp; B</title></entry>" { t.Errorf("got %q",got) }
+	if _,e := d.Query(&QueryElement{Selectors:[]string{"//missing"}}); !errors.Is(e,ErrNotFound) { t.Errorf("got %v, want ErrNotFound",e) }
+}
+
+func TestXMLCharsets(t *testing.T) {
+	tests := []struct{ name,body,ct string }{
+		{"header",`<r><t>Gr`+"\xfc\xdf"+`e</t></r>`,"application/xml; charset=iso-8859-1"},
+		{"header and declaration",`<?xml version="1.0" encoding="iso-8859-1"?><r><t>Gr`+"\xfc\xdf"+`e</t></r>`,"text/xml; charset=ISO-8859-1"},
+		{"declaration",`<?xml version="1.0" encoding="windows-1252"?><r><t>Gr`+"\xfc\xdf"+`e</t></r>`,"application/xml"},
+		{"utf-8",`<r><t>Grüße</t></r>`,"application/rss+xml; charset=utf-8"},
+	}
+	for _,tt := range tests {
+		if got := mustQuery(t,parse(t,tt.body,tt.ct),&QueryElement{Selectors:[]string{"//t"},Tag:"p"}); got!="<p>Grüße</p>" { t.Errorf("%s: got %q",tt.name,got) }
+	}
+}
+
+func TestUnsupportedType(t *testing.T) {
+	for _,ct := range []string{"image/png","application/octet-stream","application/pdf","text/plain","text/csv; charset=utf-8"} {
+		_,e := Parse(strings.NewReader("<script>x</script>"),ct,nil)
+		var ue *UnsupportedTypeError
+		if !errors.As(e,&ue) { t.Errorf("%s: got %v, want an UnsupportedTypeError",ct,e) }
+	}
+	for _,ct := range []string{"","text/html","application/xhtml+xml"} {
+		if _,e := Parse(strings.NewReader("<p>x</p>"),ct,nil); e!=nil { t.Errorf("%q: %v",ct,e) }
+	}
+}
+
+type constDocument string
+
+func (d constDocument) Query(q *QueryElement) (string,error) { return string(d),nil }
+
+func TestRegisterParser(t *testing.T) {
+	RegisterParser("application/x-test",func(body io.Reader,ct string,u *url.URL) (Document,error) { return constDocument("custom"),nil })
+	if got := mustQuery(t,parse(t,"","application/x-test"),&QueryElement{}); got!="custom" { t.Errorf("got %q",got) }
+}
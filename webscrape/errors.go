@@ -0,0 +1,82 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Reported for a QueryElement, whose Selectors did not match any element.
+var ErrNotFound = errors.New("webscrape: no element matches the selectors")
+
+// Returned, if the upstream responded with a non-2xx status code.
+type StatusError struct{
+	StatusCode int
+	Status     string
+}
+func (e *StatusError) Error() string {
+	return "webscrape: unexpected status "+e.Status
+}
+
+// Returned by Parse for a response, whose Content-Type has no Parser.
+type UnsupportedTypeError struct{
+	MediaType string
+}
+func (e *UnsupportedTypeError) Error() string {
+	return "webscrape: unsupported content type "+e.MediaType
+}
+
+// The failure of a single QueryElement.
+type QueryError struct{
+	Query *QueryElement
+	Err   error
+}
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%v: %v",e.Query.Selectors,e.Err)
+}
+func (e *QueryError) Unwrap() error { return e.Err }
+
+/*
+ The error returned by GetFragments, if one or more QueryElements failed.
+ It contains one entry per failed QueryElement.
+ */
+type Report struct{
+	Request *http.Request
+	Errors  []*QueryError
+}
+func (r *Report) Error() string {
+	s := make([]string,len(r.Errors))
+	for i,e := range r.Errors { s[i] = e.Error() }
+	u := ""
+	if r.Request!=nil && r.Request.URL!=nil { u = r.Request.URL.String() }
+	return "webscrape: "+u+": "+strings.Join(s,"; ")
+}
+
+// Returns the error of the given QueryElement or nil if it succeeded.
+func (r *Report) Err(q *QueryElement) error {
+	for _,e := range r.Errors {
+		if e.Query==q { return e.Err }
+	}
+	return nil
+}
+
+// Returns the QueryErrors, so that errors.Is and errors.As see their causes.
+func (r *Report) Unwrap() []error {
+	l := make([]error,len(r.Errors))
+	for i,e := range r.Errors { l[i] = e }
+	return l
+}
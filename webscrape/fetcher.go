@@ -0,0 +1,132 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Counters of a Fetcher.
+type FetcherStats struct{
+	Hits     uint64 // requests, that joined an in-flight fetch
+	Misses   uint64 // requests, that started a fetch
+	InFlight int    // fetches currently running
+}
+
+/*
+ Fetcher collapses identical concurrent requests into a single fetch
+ (singleflight). The parsed document is shared by all callers waiting on it.
+ Requests are identical, if their method, their URL and the values of the
+ Headers are equal. Only GET and HEAD requests without a body are collapsed.
+ A fetch is canceled, once all of its callers have given up.
+
+ The zero value of Fetcher (with a Client) is ready to use.
+ */
+type Fetcher struct{
+	Client  HttpClient
+
+	// The names of the headers, that are part of the key (eg. "Cookie" or
+	// "Accept-Language").
+	Headers []string
+
+	mutex   sync.Mutex
+	calls   map[string]*flight
+	hits    uint64
+	misses  uint64
+}
+
+func (f *Fetcher) key(r *http.Request) (string,bool) {
+	if (r.Method!="GET" && r.Method!="HEAD" && r.Method!="") || (r.Body!=nil && r.Body!=http.NoBody) {
+		return "",false
+	}
+	k := new(strings.Builder)
+	k.WriteString(r.Method+" "+r.URL.String())
+	for _,h := range f.Headers {
+		k.WriteString("\n"+http.CanonicalHeaderKey(h)+": "+strings.Join(r.Header.Values(h),", "))
+	}
+	return k.String(),true
+}
+
+// a shared fetch and the number of callers waiting on it.
+type flight struct{
+	*call
+	waiters int
+	cancel  context.CancelFunc
+}
+
+/*
+ starts or joins the fetch of r. The caller must call leave, when it stops
+ waiting; The fetch is canceled, when the last caller leaves before it is done.
+ */
+func (f *Fetcher) do(r *http.Request) (c *call,leave func()) {
+	k,ok := f.key(r)
+	if !ok { return start(f.Client,r),func(){} }
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.calls==nil { f.calls = make(map[string]*flight) }
+	if fl,ok := f.calls[k]; ok {
+		atomic.AddUint64(&f.hits,1)
+		fl.waiters++
+		return fl.call,f.leave(k,fl)
+	}
+	atomic.AddUint64(&f.misses,1)
+
+	// The fetch must not be canceled, if the caller, that started it, gives up.
+	ctx,cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	fl := &flight{call:start(f.Client,r.WithContext(ctx)),waiters:1,cancel:cancel}
+	f.calls[k] = fl
+	go func(){
+		<- fl.done
+		cancel()
+		f.mutex.Lock()
+		if f.calls[k]==fl { delete(f.calls,k) }
+		f.mutex.Unlock()
+	}()
+	return fl.call,f.leave(k,fl)
+}
+
+// returns the function, that removes a caller from fl and cancels it after the last one.
+func (f *Fetcher) leave(k string,fl *flight) func() {
+	return func(){
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		fl.waiters--
+		if fl.waiters>0 { return }
+		fl.cancel()
+		if f.calls[k]==fl { delete(f.calls,k) }
+	}
+}
+
+/*
+ Like GetFragmentsContext, but identical concurrent requests are performed
+ only once.
+ */
+func (f *Fetcher) GetFragments(ctx context.Context, r *http.Request, qs []*QueryElement) error {
+	c,leave := f.do(r)
+	res := c.await(ctx)
+	leave()
+	return fragments(r,qs,res)
+}
+
+// Returns the counters of f.
+func (f *Fetcher) Stats() FetcherStats {
+	f.mutex.Lock()
+	n := len(f.calls)
+	f.mutex.Unlock()
+	return FetcherStats{atomic.LoadUint64(&f.hits),atomic.LoadUint64(&f.misses),n}
+}
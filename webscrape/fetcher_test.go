@@ -0,0 +1,129 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"github.com/maxymania/scrapland/container"
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetcherCollapses(t *testing.T) {
+	var n atomic.Int32
+	release := make(chan struct{})
+	s := serve(t,func(w http.ResponseWriter,r *http.Request) {
+		n.Add(1)
+		<- release
+		page(`<p>`+r.Header.Get("Accept-Language")+`</p><i>i</i>`)(w,r)
+	})
+	f := &Fetcher{Client:http.DefaultClient,Headers:[]string{"Accept-Language"}}
+	wg := new(sync.WaitGroup)
+	qs := make([][]*QueryElement,6)
+	for i := range qs {
+		r := get(t,s.URL)
+		r.Header.Set("Accept-Language",[]string{"de","en"}[i%2])
+		sel := []string{"p","i"}[i/3]
+		qs[i] = []*QueryElement{{Selectors:[]string{sel},Element:container.NewElement()}}
+		wg.Add(1)
+		go func(){
+			defer wg.Done()
+			f.GetFragments(context.Background(),r,qs[i])
+		}()
+	}
+	waitFor(t,func() bool { return f.Stats().Hits+f.Stats().Misses>=6 })
+	if st := f.Stats(); st.Misses!=2 || st.Hits!=4 || st.InFlight!=2 { t.Errorf("got %+v, want 2 misses, 4 hits, 2 in flight",st) }
+	close(release)
+	wg.Wait()
+	if n.Load()!=2 { t.Errorf("the server got %d requests, want 2",n.Load()) }
+	for i,q := range qs {
+		want := []string{"de","en"}[i%2]
+		if i>=3 { want = "i" }
+		if got := q[0].Element.Get(); got!=want { t.Errorf("%d: got %q, want %q",i,got,want) }
+	}
+}
+
+func TestFetcherCancel(t *testing.T) {
+	release := make(chan struct{})
+	s := serve(t,func(w http.ResponseWriter,r *http.Request) {
+		<- release
+		page(`<p>ok</p>`)(w,r)
+	})
+	f := &Fetcher{Client:http.DefaultClient}
+
+	// the first caller gives up, the second one still gets the result.
+	ctx,cancel := context.WithCancel(context.Background())
+	q1 := []*QueryElement{{Selectors:[]string{"p"},Element:container.NewElement(),Fallback:"gave up"}}
+	done := make(chan struct{})
+	go func(){
+		f.GetFragments(ctx,get(t,s.URL),q1)
+		close(done)
+	}()
+	waitFor(t,func() bool { return f.Stats().Misses>=1 })
+	q2 := []*QueryElement{{Selectors:[]string{"p"},Element:container.NewElement()}}
+	go f.GetFragments(context.Background(),get(t,s.URL),q2)
+	waitFor(t,func() bool { return f.Stats().Hits>=1 })
+	cancel()
+	<- done
+	close(release)
+	if q1[0].Element.Get()!="gave up" || q2[0].Element.Get()!="ok" { t.Errorf("got %q and %q",q1[0].Element.Get(),q2[0].Element.Get()) }
+}
+
+func TestFetcherHungUpstream(t *testing.T) {
+	canceled := make(chan struct{},2)
+	s := serve(t,func(w http.ResponseWriter,r *http.Request) {
+		<- r.Context().Done()
+		canceled <- struct{}{}
+	})
+	f := &Fetcher{Client:http.DefaultClient}
+	giveUp := func() {
+		ctx,cancel := context.WithTimeout(context.Background(),50*time.Millisecond)
+		defer cancel()
+		q := []*QueryElement{{Selectors:[]string{"p"},Element:container.NewElement(),Fallback:"gave up"}}
+		f.GetFragments(ctx,get(t,s.URL),q)
+		if q[0].Element.Get()!="gave up" { t.Errorf("got %q",q[0].Element.Get()) }
+	}
+	done := make(chan struct{})
+	go func(){
+		giveUp()
+		close(done)
+	}()
+	giveUp()
+	<- done
+	if st := f.Stats(); st.InFlight!=0 || st.Misses+st.Hits!=2 { t.Errorf("after the callers gave up: %+v",st) }
+	select {
+	case <- canceled:
+	case <- time.After(5*time.Second):
+		t.Fatal("the upstream request was not canceled")
+	}
+
+	// a later caller starts a new fetch instead of joining the dead one.
+	giveUp()
+	if st := f.Stats(); st.Misses<2 { t.Errorf("the later caller joined the dead fetch: %+v",st) }
+}
+
+func TestFetcherNotCollapsed(t *testing.T) {
+	var n atomic.Int32
+	s := serve(t,func(w http.ResponseWriter,r *http.Request) {
+		n.Add(1)
+		page(`<p>ok</p>`)(w,r)
+	})
+	f := &Fetcher{Client:http.DefaultClient}
+	r,_ := http.NewRequest("POST",s.URL,nil)
+	f.GetFragments(context.Background(),r,nil)
+	if st := f.Stats(); st.Misses!=0 || n.Load()!=1 { t.Errorf("POST: got %+v",st) }
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"github.com/maxymania/scrapland/htmlscrape"
+	"net/http"
+	"net/url"
+)
+
+/*
+ Fetches the page r and returns the form, that is found with the selectors
+ sels (see htmlscrape.LurkFor), or the first form, if sels is empty. The base
+ URL of the page is returned as well, as it is needed to submit the form:
+
+	form,base,err := webscrape.GetForm(hc,r,"#search")
+	...
+	form.Set("q","golang")
+	req,err := form.Request(base)
+	...
+	err = webscrape.GetFragments(hc,req,qs)
+ */
+func GetForm(hc HttpClient, r *http.Request, sels ...string) (*htmlscrape.Form,*url.URL,error) {
+	f := fetch(hc,r)
+	if f.e!=nil { return nil,nil,f.e }
+	hd,ok := f.d.(HTMLDocument)
+	if !ok { return nil,nil,ErrNotFound }
+	if len(sels)==0 { sels = []string{"form"} }
+	n := hd.Node()
+	for _,sel := range sels {
+		if n = htmlscrape.LurkFor(n,sel); n==nil { return nil,nil,ErrNotFound }
+	}
+	form := htmlscrape.ParseForm(n)
+	if form==nil { return nil,nil,ErrNotFound }
+	return form,hd.Base(),nil
+}
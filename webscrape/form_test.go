@@ -0,0 +1,40 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetForm(t *testing.T) {
+	s := serve(t,page(`<base href="/app/"><form id="a" action="a"><input name="x" value="1"></form><div id="d"><form action="search"><input name="q"></form></div>`))
+	f,base,e := GetForm(http.DefaultClient,get(t,s.URL))
+	if e!=nil { t.Fatal(e) }
+	if f.Field("x")==nil { t.Errorf("not the first form") }
+	r,e := f.Request(base)
+	if e!=nil { t.Fatal(e) }
+	if r.URL.String()!=s.URL+"/app/a?x=1" { t.Errorf("got %s",r.URL) }
+
+	f,_,e = GetForm(http.DefaultClient,get(t,s.URL),"#d","form")
+	if e!=nil || f.Field("q")==nil { t.Errorf("got %v, %v",f,e) }
+	if _,_,e = GetForm(http.DefaultClient,get(t,s.URL),"#missing"); e!=ErrNotFound { t.Errorf("got %v, want ErrNotFound",e) }
+	if _,_,e = GetForm(http.DefaultClient,get(t,s.URL),"#d"); e!=ErrNotFound { t.Errorf("a div: got %v, want ErrNotFound",e) }
+
+	s = serve(t,func(w http.ResponseWriter,r *http.Request) {
+		w.Header().Set("Content-Type","application/json")
+		w.Write([]byte(`{}`))
+	})
+	if _,_,e = GetForm(http.DefaultClient,get(t,s.URL)); e!=ErrNotFound { t.Errorf("JSON: got %v, want ErrNotFound",e) }
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ A cookie jar, that can be saved to and loaded from a file, so that sessions
+ survive a restart. It uses a cookiejar.Jar internally.
+ */
+type FileJar struct{
+	*cookiejar.Jar
+	name    string
+	mutex   sync.Mutex
+	cookies map[string][]*http.Cookie // keyed by URL
+}
+
+/*
+ Creates a FileJar, that is stored in the file name. If the file exists, its
+ cookies are loaded. If name is empty, the jar is kept in memory only.
+ */
+func NewFileJar(name string) (*FileJar,error) {
+	j,e := cookiejar.New(nil)
+	if e!=nil { return nil,e }
+	fj := &FileJar{Jar:j,name:name,cookies:make(map[string][]*http.Cookie)}
+	if name=="" { return fj,nil }
+	b,e := os.ReadFile(name)
+	if os.IsNotExist(e) { return fj,nil }
+	if e!=nil { return nil,e }
+	var m map[string][]*http.Cookie
+	if e = json.Unmarshal(b,&m); e!=nil { return nil,e }
+	now := time.Now()
+	for k,cs := range m {
+		u,e := url.Parse(k)
+		if e!=nil { continue }
+		var live []*http.Cookie
+		for _,c := range cs {
+			if c.Expires.IsZero() || c.Expires.After(now) { live = append(live,c) }
+		}
+		fj.SetCookies(u,live)
+	}
+	return fj,nil
+}
+
+/*
+ returns a copy of the cookie c, that has been set by u, for saving: MaxAge
+ is converted to Expires and the default Path (RFC 6265, 5.1.4) is filled
+ in, as the cookies are replayed against "/" after a restart. Host-only
+ cookies keep an empty Domain, they are replayed against their host. The
+ second result is false, if the cookie deletes a stored one.
+ */
+func savedCookie(u *url.URL,c *http.Cookie,now time.Time) (*http.Cookie,bool) {
+	s := *c
+	if !strings.HasPrefix(s.Path,"/") {
+		s.Path = "/"
+		if i := strings.LastIndexByte(u.Path,'/'); i>0 { s.Path = u.Path[:i] }
+	}
+	s.Domain = strings.TrimPrefix(strings.ToLower(s.Domain),".")
+	s.Raw,s.RawExpires = "",""
+	switch {
+	case s.MaxAge<0:
+		return &s,false
+	case s.MaxAge>0:
+		s.Expires = now.Add(time.Duration(s.MaxAge)*time.Second)
+		s.MaxAge = 0
+	case !s.Expires.IsZero() && !s.Expires.After(now):
+		return &s,false
+	}
+	return &s,true
+}
+
+func (j *FileJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Jar.SetCookies(u,cookies)
+	k := (&url.URL{Scheme:u.Scheme,Host:u.Host,Path:"/"}).String()
+	now := time.Now()
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	old := j.cookies[k]
+	for _,c := range cookies {
+		s,keep := savedCookie(u,c,now)
+		i := slices.IndexFunc(old,func(o *http.Cookie) bool {
+			return o.Name==s.Name && o.Path==s.Path && o.Domain==s.Domain
+		})
+		switch {
+		case i>=0 && keep: old[i] = s
+		case i>=0:         old = slices.Delete(old,i,i+1)
+		case keep:         old = append(old,s)
+		}
+	}
+	j.cookies[k] = old
+}
+
+// Writes the cookies to the file. Does nothing, if the jar has no file.
+func (j *FileJar) Save() error {
+	if j.name=="" { return nil }
+	j.mutex.Lock()
+	b,e := json.Marshal(j.cookies)
+	j.mutex.Unlock()
+	if e!=nil { return e }
+	tmp := j.name+".tmp"
+	if e = os.WriteFile(tmp,b,0600); e!=nil { return e }
+	return os.Rename(tmp,j.name)
+}
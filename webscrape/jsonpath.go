@@ -0,0 +1,112 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrJSONPath = errors.New("webscrape: invalid JSONPath")
+
+func jsonChild(v interface{},k string,l []interface{}) []interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if k=="*" {
+			keys := make([]string,0,len(t))
+			for kk := range t { keys = append(keys,kk) }
+			sort.Strings(keys)
+			for _,kk := range keys { l = append(l,t[kk]) }
+		} else if c,ok := t[k]; ok {
+			l = append(l,c)
+		}
+	case []interface{}:
+		if k=="*" { return append(l,t...) }
+		i,e := strconv.Atoi(k)
+		if e!=nil { return l }
+		if i<0 { i += len(t) }
+		if i>=0 && i<len(t) { l = append(l,t[i]) }
+	}
+	return l
+}
+
+func jsonDescend(v interface{},k string,l []interface{}) []interface{} {
+	l = jsonChild(v,k,l)
+	switch t := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string,0,len(t))
+		for kk := range t { keys = append(keys,kk) }
+		sort.Strings(keys)
+		for _,kk := range keys { l = jsonDescend(t[kk],k,l) }
+	case []interface{}:
+		for _,c := range t { l = jsonDescend(c,k,l) }
+	}
+	return l
+}
+
+/*
+ Evaluates a JSONPath expression against the decoded JSON value v. The
+ supported subset is: "$", ".name", "['name']", "[n]" (negative n counts from
+ the end), "[*]", ".*" and "..name" (recursive descent).
+
+ If the path contains a wildcard or a recursive descent, the result is a
+ []interface{} of all matches, otherwise it is the single match or nil.
+ */
+func JSONPath(v interface{},path string) (interface{},error) {
+	if !strings.HasPrefix(path,"$") { return nil,ErrJSONPath }
+	path = path[1:]
+	cur := []interface{}{v}
+	multi := false
+	for path!="" {
+		rec := false
+		var k string
+		switch {
+		case strings.HasPrefix(path,".."):
+			rec,multi = true,true
+			path = path[2:]
+			fallthrough
+		case path[0]=='.':
+			if !rec { path = path[1:] }
+			i := strings.IndexAny(path,".[")
+			if i<0 { i = len(path) }
+			k,path = path[:i],path[i:]
+			if k=="" { return nil,ErrJSONPath }
+		case path[0]=='[':
+			i := strings.IndexByte(path,']')
+			if i<0 { return nil,ErrJSONPath }
+			k,path = strings.TrimSpace(path[1:i]),path[i+1:]
+			if len(k)>=2 && (k[0]=='\'' || k[0]=='"') && k[len(k)-1]==k[0] { k = k[1:len(k)-1] }
+		default:
+			return nil,ErrJSONPath
+		}
+		if k=="*" { multi = true }
+		var next []interface{}
+		for _,c := range cur {
+			if rec {
+				next = jsonDescend(c,k,next)
+			} else {
+				next = jsonChild(c,k,next)
+			}
+		}
+		cur = next
+	}
+	if multi {
+		if len(cur)==0 { return nil,nil }
+		return cur,nil
+	}
+	if len(cur)==0 { return nil,nil }
+	return cur[0],nil
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Returned by a CircuitBreaker, while the circuit of a host is open.
+var ErrCircuitOpen = errors.New("webscrape: circuit open")
+
+// An adapter to use ordinary functions as HttpClient.
+type ClientFunc func(req *http.Request) (*http.Response,error)
+
+func (f ClientFunc) Do(req *http.Request) (*http.Response,error) { return f(req) }
+
+// A Middleware wraps an HttpClient.
+type Middleware func(next HttpClient) HttpClient
+
+/*
+ Wraps hc into the middlewares. The first one is the outermost, so the
+ recommended order is:
+
+	webscrape.Wrap(http.DefaultClient,
+		webscrape.CircuitBreaker(5,30*time.Second),
+		webscrape.Retry(webscrape.RetryPolicy{MaxRetries:3}),
+		webscrape.RateLimit(2,5))
+
+ This way, every retry consumes a token and the circuit breaker sees only
+ the final outcome of a request.
+ */
+func Wrap(hc HttpClient, mw ...Middleware) HttpClient {
+	for i := len(mw)-1; i>=0; i-- { hc = mw[i](hc) }
+	return hc
+}
+
+func sleep(ctx context.Context,d time.Duration) error {
+	if d<=0 { return ctx.Err() }
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <- t.C:
+		return nil
+	case <- ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type bucket struct{
+	tokens float64
+	last   time.Time
+}
+
+/*
+ Limits the requests per host with a token bucket, that holds up to burst
+ tokens and is refilled with rate tokens per second. A request waits for a
+ token (or until its context is done, in which case the token is returned).
+ */
+func RateLimit(rate float64, burst int) Middleware {
+	if burst<1 { burst = 1 }
+	mutex := new(sync.Mutex)
+	buckets := make(map[string]*bucket)
+	reserve := func(host string) time.Duration {
+		mutex.Lock()
+		defer mutex.Unlock()
+		now := time.Now()
+		b,ok := buckets[host]
+		if !ok {
+			b = &bucket{float64(burst),now}
+			buckets[host] = b
+		}
+		b.tokens += now.Sub(b.last).Seconds()*rate
+		if b.tokens>float64(burst) { b.tokens = float64(burst) }
+		b.last = now
+		b.tokens--
+		if b.tokens>=0 { return 0 }
+		return time.Duration(-b.tokens/rate*float64(time.Second))
+	}
+	// returns the token of a request, that gave up waiting.
+	refund := func(host string) {
+		mutex.Lock()
+		buckets[host].tokens++
+		mutex.Unlock()
+	}
+	return func(next HttpClient) HttpClient {
+		return ClientFunc(func(req *http.Request) (*http.Response,error) {
+			if e := req.Context().Err(); e!=nil { return nil,e }
+			if e := sleep(req.Context(),reserve(req.URL.Host)); e!=nil {
+				refund(req.URL.Host)
+				return nil,e
+			}
+			return next.Do(req)
+		})
+	}
+}
+
+// Controls the Retry middleware.
+type RetryPolicy struct{
+	// The number of retries after the first attempt.
+	MaxRetries int
+
+	// The delay before the first retry, doubled for each further retry.
+	// Defaults to 100ms.
+	BaseDelay  time.Duration
+
+	// The maximum delay between two attempts. If the server asks for a
+	// longer delay (Retry-After), its response is returned. Defaults to 30s.
+	MaxDelay   time.Duration
+}
+
+// Reports, whether a response with the status code should be retried.
+func Retryable(code int) bool {
+	switch code {
+	case 429,500,502,503,504:
+		return true
+	}
+	return false
+}
+
+// parses a Retry-After header (seconds or HTTP date).
+func retryAfter(resp *http.Response) (time.Duration,bool) {
+	v := resp.Header.Get("Retry-After")
+	if v=="" { return 0,false }
+	if s,e := strconv.Atoi(v); e==nil { return time.Duration(s)*time.Second,true }
+	if t,e := http.ParseTime(v); e==nil { return time.Until(t),true }
+	return 0,false
+}
+
+/*
+ Retries requests, that failed with a network error or a retryable status
+ (429 and 5xx, see Retryable), with exponential backoff and jitter. The
+ Retry-After header is honored. Requests with a body are only retried, if
+ they have GetBody (as the requests created by http.NewRequest).
+ */
+func Retry(p RetryPolicy) Middleware {
+	if p.BaseDelay<=0 { p.BaseDelay = 100*time.Millisecond }
+	if p.MaxDelay<=0 { p.MaxDelay = 30*time.Second }
+	return func(next HttpClient) HttpClient {
+		return ClientFunc(func(req *http.Request) (*http.Response,error) {
+			delay := p.BaseDelay
+			for i := 0; ; i++ {
+				resp,err := next.Do(req)
+				if i>=p.MaxRetries || req.Context().Err()!=nil { return resp,err }
+				if req.Body!=nil && req.Body!=http.NoBody && req.GetBody==nil { return resp,err }
+				if err==nil && !Retryable(resp.StatusCode) { return resp,err }
+
+				// full jitter in [delay/2,delay]
+				d := delay/2+time.Duration(rand.Int63n(int64(delay/2)+1))
+				if err==nil {
+					if ra,ok := retryAfter(resp); ok {
+						if ra>p.MaxDelay { return resp,err }
+						if ra>d { d = ra }
+					}
+					io.Copy(io.Discard,io.LimitReader(resp.Body,1<<16))
+					resp.Body.Close()
+				}
+				if e := sleep(req.Context(),d); e!=nil { return nil,e }
+				if req.GetBody!=nil {
+					body,e := req.GetBody()
+					if e!=nil { return nil,e }
+					req = req.Clone(req.Context())
+					req.Body = body
+				}
+				delay *= 2
+				if delay>p.MaxDelay { delay = p.MaxDelay }
+			}
+		})
+	}
+}
+
+type circuit struct{
+	failures int
+	open     time.Time // zero, if closed
+	trial    bool      // a trial request is running (half-open)
+}
+
+/*
+ Fails fast with ErrCircuitOpen for a host, after threshold consecutive
+ requests to it failed (network errors and 5xx responses). After cooldown,
+ a single trial request is let through: If it succeeds, the circuit is
+ closed again, otherwise it stays open for another cooldown.
+ */
+func CircuitBreaker(threshold int, cooldown time.Duration) Middleware {
+	if threshold<1 { threshold = 1 }
+	mutex := new(sync.Mutex)
+	circuits := make(map[string]*circuit)
+	return func(next HttpClient) HttpClient {
+		return ClientFunc(func(req *http.Request) (*http.Response,error) {
+			host := req.URL.Host
+			mutex.Lock()
+			c,ok := circuits[host]
+			if !ok {
+				c = new(circuit)
+				circuits[host] = c
+			}
+			trial := false
+			if !c.open.IsZero() {
+				if c.trial || time.Since(c.open)<cooldown {
+					mutex.Unlock()
+					return nil,ErrCircuitOpen
+				}
+				c.trial,trial = true,true
+			}
+			mutex.Unlock()
+
+			resp,err := next.Do(req)
+			failed := err!=nil || resp.StatusCode>=500
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if trial { c.trial = false }
+			// a canceled request tells nothing about the host.
+			if err!=nil && req.Context().Err()!=nil { return resp,err }
+			if !failed {
+				c.failures = 0
+				c.open = time.Time{}
+				return resp,err
+			}
+			c.failures++
+			if trial || c.failures>=threshold { c.open = time.Now() }
+			return resp,err
+		})
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// a client, that answers with the status codes in turn (0 is a network error).
+type scripted struct{
+	codes  []int
+	header http.Header
+	calls  atomic.Int32
+}
+
+var errNetwork = errors.New("network error")
+
+func (s *scripted) Do(req *http.Request) (*http.Response,error) {
+	i := int(s.calls.Add(1))-1
+	code := s.codes[len(s.codes)-1]
+	if i<len(s.codes) { code = s.codes[i] }
+	if code==0 { return nil,errNetwork }
+	h := s.header
+	if h==nil { h = make(http.Header) }
+	return &http.Response{StatusCode:code,Header:h,Body:io.NopCloser(strings.NewReader("")),Request:req},nil
+}
+
+func TestRateLimit(t *testing.T) {
+	hc := Wrap(&scripted{codes:[]int{200}},RateLimit(20,2))
+	do := func(ctx context.Context,u string) error {
+		r,_ := http.NewRequestWithContext(ctx,"GET",u,nil)
+		_,e := hc.Do(r)
+		return e
+	}
+	start := time.Now()
+	for i := 0; i<4; i++ { do(context.Background(),"http://a/") }
+	// the burst of 2 is free, the next 2 requests wait 50ms each
+	if d := time.Since(start); d<90*time.Millisecond { t.Errorf("4 requests took %v",d) }
+	start = time.Now()
+	do(context.Background(),"http://b/")
+	if d := time.Since(start); d>40*time.Millisecond { t.Errorf("another host waited %v",d) }
+
+	// canceled requests do not consume tokens
+	ctx,cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i<10; i++ {
+		if e := do(ctx,"http://c/"); !errors.Is(e,context.Canceled) { t.Fatalf("got %v",e) }
+	}
+	// neither do requests, that give up waiting
+	do(context.Background(),"http://c/")
+	do(context.Background(),"http://c/")
+	for i := 0; i<5; i++ {
+		ctx,cancel := context.WithTimeout(context.Background(),5*time.Millisecond)
+		if e := do(ctx,"http://c/"); !errors.Is(e,context.DeadlineExceeded) { t.Fatalf("got %v",e) }
+		cancel()
+	}
+	start = time.Now()
+	do(context.Background(),"http://c/")
+	if d := time.Since(start); d>80*time.Millisecond { t.Errorf("waited %v after canceled requests",d) }
+}
+
+func TestRetry(t *testing.T) {
+	var bodies []string
+	ts := serve(t,func(w http.ResponseWriter,r *http.Request) {
+		b,_ := io.ReadAll(r.Body)
+		bodies = append(bodies,string(b))
+		if len(bodies)<3 {
+			w.WriteHeader(503)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	hc := Wrap(http.DefaultClient,Retry(RetryPolicy{MaxRetries:3,BaseDelay:time.Millisecond}))
+	r,_ := http.NewRequest("POST",ts.URL,strings.NewReader("payload"))
+	resp,e := hc.Do(r)
+	if e!=nil { t.Fatal(e) }
+	resp.Body.Close()
+	if resp.StatusCode!=200 { t.Errorf("status %d",resp.StatusCode) }
+	if len(bodies)!=3 || bodies[0]!="payload" || bodies[2]!="payload" { t.Errorf("bodies %q",bodies) }
+
+	tests := []struct{
+		name   string
+		c      *scripted
+		p      RetryPolicy
+		body   io.Reader
+		calls  int32
+		status int
+		err    error
+	}{
+		{"exhausted",&scripted{codes:[]int{500}},RetryPolicy{MaxRetries:2},nil,3,500,nil},
+		{"network",&scripted{codes:[]int{0,0,200}},RetryPolicy{MaxRetries:2},nil,3,200,nil},
+		{"not retryable",&scripted{codes:[]int{404,200}},RetryPolicy{MaxRetries:2},nil,1,404,nil},
+		{"no GetBody",&scripted{codes:[]int{503,200}},RetryPolicy{MaxRetries:2},io.MultiReader(strings.NewReader("x")),1,503,nil},
+		{"Retry-After too long",&scripted{codes:[]int{429,200},header:http.Header{"Retry-After":{"60"}}},RetryPolicy{MaxRetries:2,MaxDelay:time.Second},nil,1,429,nil},
+		{"Retry-After",&scripted{codes:[]int{429,200},header:http.Header{"Retry-After":{"0"}}},RetryPolicy{MaxRetries:2},nil,2,200,nil},
+	}
+	for _,tt := range tests {
+		if tt.p.BaseDelay==0 { tt.p.BaseDelay = time.Millisecond }
+		r,_ := http.NewRequest("POST","http://a/",tt.body)
+		resp,e := Wrap(tt.c,Retry(tt.p)).Do(r)
+		if n := tt.c.calls.Load(); n!=tt.calls { t.Errorf("%s: %d calls, want %d",tt.name,n,tt.calls) }
+		if e!=nil || resp.StatusCode!=tt.status { t.Errorf("%s: got %v, %v",tt.name,resp,e) }
+	}
+
+	ctx,cancel := context.WithTimeout(context.Background(),20*time.Millisecond)
+	defer cancel()
+	r,_ = http.NewRequestWithContext(ctx,"GET","http://a/",nil)
+	c := &scripted{codes:[]int{503}}
+	if _,e := Wrap(c,Retry(RetryPolicy{MaxRetries:100,BaseDelay:10*time.Millisecond})).Do(r); !errors.Is(e,context.DeadlineExceeded) { t.Errorf("got %v",e) }
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	c := &scripted{codes:[]int{500,0,200,500,200,404}}
+	hc := Wrap(c,CircuitBreaker(2,30*time.Millisecond))
+	do := func(u string) error {
+		r,_ := http.NewRequest("GET",u,nil)
+		_,e := hc.Do(r)
+		return e
+	}
+	do("http://a/")
+	do("http://a/") // the second failure opens the circuit
+	if e := do("http://a/"); e!=ErrCircuitOpen { t.Fatalf("got %v, want ErrCircuitOpen",e) }
+	if n := c.calls.Load(); n!=2 { t.Errorf("the open circuit called the client (%d calls)",n) }
+	if e := do("http://b/"); e!=nil { t.Errorf("another host: %v",e) } // 200
+
+	time.Sleep(40*time.Millisecond)
+	do("http://a/") // the trial fails (500), the circuit stays open
+	if e := do("http://a/"); e!=ErrCircuitOpen { t.Fatalf("got %v, want ErrCircuitOpen",e) }
+
+	time.Sleep(40*time.Millisecond)
+	if e := do("http://a/"); e!=nil { t.Fatal(e) } // the trial succeeds (200)
+	if e := do("http://a/"); e!=nil { t.Errorf("the circuit did not close: %v",e) } // 404 is no failure
+	if n := c.calls.Load(); n!=6 { t.Errorf("%d calls, want 6",n) }
+}
+
+func TestCircuitBreakerTrial(t *testing.T) {
+	// a canceled request does not count as a failure
+	hc := Wrap(ClientFunc(func(req *http.Request) (*http.Response,error) { return nil,errNetwork }),CircuitBreaker(1,time.Hour))
+	ctx,cancel := context.WithCancel(context.Background())
+	cancel()
+	r,_ := http.NewRequestWithContext(ctx,"GET","http://a/",nil)
+	hc.Do(r)
+	r,_ = http.NewRequest("GET","http://a/",nil)
+	if _,e := hc.Do(r); e!=errNetwork { t.Errorf("a canceled request opened the circuit: %v",e) }
+
+	// only one trial request runs at a time
+	release := make(chan struct{})
+	var calls atomic.Int32
+	hc = Wrap(ClientFunc(func(req *http.Request) (*http.Response,error) {
+		if calls.Add(1)==1 { return nil,errNetwork }
+		<- release
+		return &http.Response{StatusCode:200,Body:http.NoBody},nil
+	}),CircuitBreaker(1,10*time.Millisecond))
+	do := func() error {
+		r,_ := http.NewRequest("GET","http://a/",nil)
+		_,e := hc.Do(r)
+		return e
+	}
+	do()
+	time.Sleep(20*time.Millisecond)
+	done := make(chan error)
+	go func(){ done <- do() }()
+	waitFor(t,func() bool { return calls.Load()==2 })
+	if e := do(); e!=ErrCircuitOpen { t.Errorf("a second trial: %v",e) }
+	close(release)
+	if e := <- done; e!=nil { t.Errorf("trial: %v",e) }
+	if e := do(); e!=nil { t.Errorf("after the trial: %v",e) }
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"io"
+	"strings"
+)
+
+var proxyHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Cache-Control",
+	"Expires",
+	"Last-Modified",
+	"ETag",
+}
+
+/*
+ Proxy is a http.Handler, that fetches the URL passed in the "url" query
+ parameter and forwards the response. It is the counterpart of
+ htmlscrape.ProxyURL and is meant to be mounted in an override.Overrider:
+
+	o.Add("/proxy/",&webscrape.Proxy{Client:http.DefaultClient,Allow:webscrape.AllowHosts("example.com")})
+	q := &webscrape.QueryElement{ ... , URLMap:htmlscrape.ProxyURL("/proxy/")}
+
+ Only http and https URLs are fetched. As the responses are served from our
+ origin, they are sandboxed (Content-Security-Policy: sandbox) and their
+ Content-Type is not sniffed, so that scripts of an upstream page or SVG
+ image do not run as us.
+ */
+type Proxy struct{
+	Client HttpClient
+
+	// Only URLs for which Allow returns true are fetched. If Allow is nil,
+	// every request is refused, as the Proxy would be an open proxy otherwise.
+	// If Client is an *http.Client, redirects are checked as well.
+	Allow func(*url.URL) bool
+}
+
+/*
+ Returns a function for Proxy.Allow, that allows the hosts (eg. "example.com")
+ and their subdomains (eg. "www.example.com").
+ */
+func AllowHosts(hosts ...string) func(*url.URL) bool {
+	return func(u *url.URL) bool {
+		h := strings.ToLower(u.Hostname())
+		for _,a := range hosts {
+			a = strings.ToLower(a)
+			if h==a || strings.HasSuffix(h,"."+a) { return true }
+		}
+		return false
+	}
+}
+
+var errRedirectDenied = errors.New("webscrape: redirect target not allowed")
+
+func (p *Proxy) allowed(u *url.URL) bool {
+	if p.Allow==nil || u.Host=="" { return false }
+	if u.Scheme!="http" && u.Scheme!="https" { return false }
+	return p.Allow(u)
+}
+
+// the client, that refuses redirects to URLs, that are not allowed.
+func (p *Proxy) client() HttpClient {
+	hc,ok := p.Client.(*http.Client)
+	if !ok { return p.Client }
+	c := *hc
+	check := hc.CheckRedirect
+	c.CheckRedirect = func(r *http.Request,via []*http.Request) error {
+		if !p.allowed(r.URL) { return errRedirectDenied }
+		if check!=nil { return check(r,via) }
+		if len(via)>=10 { return errors.New("stopped after 10 redirects") }
+		return nil
+	}
+	return &c
+}
+
+func (p *Proxy) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.Method!="GET" && req.Method!="HEAD" {
+		http.Error(resp,"Method Not Allowed",405)
+		return
+	}
+	u,e := url.Parse(req.URL.Query().Get("url"))
+	if e!=nil || (u.Scheme!="http" && u.Scheme!="https") || u.Host=="" {
+		http.Error(resp,"Bad Request",400)
+		return
+	}
+	if !p.allowed(u) {
+		http.Error(resp,"Forbidden",403)
+		return
+	}
+	r,e := http.NewRequest(req.Method,u.String(),nil)
+	if e!=nil {
+		http.Error(resp,"Bad Request",400)
+		return
+	}
+	for _,h := range []string{"Accept","Accept-Language","If-None-Match","If-Modified-Since"} {
+		if v := req.Header.Get(h); v!="" { r.Header.Set(h,v) }
+	}
+	res,e := p.client().Do(r)
+	if errors.Is(e,errRedirectDenied) {
+		http.Error(resp,"Forbidden",403)
+		return
+	}
+	if e!=nil {
+		http.Error(resp,"Bad Gateway",502)
+		return
+	}
+	defer res.Body.Close()
+	rh := resp.Header()
+	for _,h := range proxyHeaders {
+		if v := res.Header.Get(h); v!="" { rh.Set(h,v) }
+	}
+	rh.Set("Content-Security-Policy","sandbox")
+	rh.Set("X-Content-Type-Options","nosniff")
+	resp.WriteHeader(res.StatusCode)
+	io.Copy(resp,res.Body)
+}
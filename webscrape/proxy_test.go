@@ -0,0 +1,91 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAbsolutizeByDefault(t *testing.T) {
+	s := serve(t,page(`<div id="c"><a href="x.html">x</a><img src="/i.png"></div>`))
+	got,e := query(t,s.URL+"/dir/page",&QueryElement{Selectors:[]string{"#c"}})
+	if e!=nil { t.Fatal(e) }
+	if want := `<a href="`+s.URL+`/dir/x.html">x</a><img src="`+s.URL+`/i.png"/>`; got!=want { t.Errorf("got %s, want %s",got,want) }
+
+	got,_ = query(t,s.URL+"/dir/page",&QueryElement{Selectors:[]string{"#c"},Raw:true})
+	if !strings.Contains(got,`href="x.html"`) { t.Errorf("Raw: URLs rewritten: %s",got) }
+}
+
+func TestProxy(t *testing.T) {
+	up := serve(t,func(w http.ResponseWriter,r *http.Request) {
+		if r.URL.Path=="/redir" {
+			http.Redirect(w,r,"http://169.254.169.254/latest/meta-data/",http.StatusFound)
+			return
+		}
+		if r.URL.Path=="/svg" {
+			w.Header().Set("Content-Type","image/svg+xml")
+			io.WriteString(w,`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)
+			return
+		}
+		w.Header().Set("Content-Type","text/plain")
+		w.Header().Set("Set-Cookie","secret=1")
+		io.WriteString(w,"upstream")
+	})
+	uu,_ := url.Parse(up.URL)
+	proxied := func(p *Proxy,target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w,httptest.NewRequest("GET","/proxy/?url="+url.QueryEscape(target),nil))
+		return w
+	}
+
+	if w := proxied(&Proxy{Client:http.DefaultClient},up.URL+"/a"); w.Code!=403 {
+		t.Errorf("no Allow: got %d, want 403",w.Code)
+	}
+	p := &Proxy{Client:http.DefaultClient,Allow:AllowHosts(uu.Hostname())}
+	w := proxied(p,up.URL+"/a")
+	if w.Code!=200 || w.Body.String()!="upstream" { t.Errorf("allowed: got %d %q",w.Code,w.Body.String()) }
+	if w.Header().Get("Set-Cookie")!="" { t.Errorf("Set-Cookie forwarded") }
+	if w.Header().Get("Content-Type")!="text/plain" { t.Errorf("Content-Type not forwarded") }
+	for _,path := range []string{"/a","/svg"} {
+		w := proxied(p,up.URL+path)
+		if w.Header().Get("Content-Security-Policy")!="sandbox" || w.Header().Get("X-Content-Type-Options")!="nosniff" {
+			t.Errorf("%s: not sandboxed: %v",path,w.Header())
+		}
+	}
+	if w := proxied(p,"http://internal.example/"); w.Code!=403 { t.Errorf("other host: got %d, want 403",w.Code) }
+	if w := proxied(p,"file:///etc/passwd"); w.Code!=400 { t.Errorf("file URL: got %d, want 400",w.Code) }
+	if w := proxied(p,up.URL+"/redir"); w.Code!=403 { t.Errorf("redirect to other host: got %d, want 403",w.Code) }
+
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w,httptest.NewRequest("POST","/proxy/?url="+url.QueryEscape(up.URL),nil))
+	if w.Code!=405 { t.Errorf("POST: got %d, want 405",w.Code) }
+}
+
+func TestAllowHosts(t *testing.T) {
+	a := AllowHosts("example.com")
+	for s,want := range map[string]bool{
+		"http://example.com/":true,
+		"https://www.EXAMPLE.com:8080/x":true,
+		"http://badexample.com/":false,
+		"http://example.com.evil.org/":false,
+	} {
+		u,_ := url.Parse(s)
+		if got := a(u); got!=want { t.Errorf("%s: got %v, want %v",s,got,want) }
+	}
+}
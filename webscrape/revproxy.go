@@ -0,0 +1,179 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"github.com/maxymania/scrapland/htmlscrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"bytes"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+ ReverseProxy is a http.Handler, that forwards the requests below Prefix to
+ the Upstream site (like httputil.ReverseProxy) and rewrites its HTML pages
+ on the fly: URLs pointing to the upstream are mapped to the Prefix, and the
+ Transforms are applied. Other content is forwarded untouched. The Location
+ header and the paths of cookies are mapped to the Prefix as well.
+
+	rp := &webscrape.ReverseProxy{
+		Upstream:upstream, // http://intranet/wiki/
+		Prefix:"/wiki/",
+		Transforms:[]htmlscrape.Transf{
+			htmlscrape.Remove("#sidebar"),
+			htmlscrape.InsertBefore("#content",`<div class="banner">Mirrored</div>`),
+		},
+	}
+	o.Add("/wiki/",rp)
+ */
+type ReverseProxy struct{
+	// The URL of the upstream site. Its path is the root, that is mapped to the Prefix.
+	Upstream   *url.URL
+
+	// The path, the ReverseProxy is mounted at, eg. "/wiki/".
+	Prefix     string
+
+	// Used to contact the upstream, http.DefaultTransport if nil.
+	Transport  http.RoundTripper
+
+	// Applied in order to every node of HTML pages (see htmlscrape.Walk),
+	// after the URLs have been mapped.
+	Transforms []htmlscrape.Transf
+
+	// HTML pages, that are larger than MaxBody bytes, are forwarded
+	// untouched instead of being buffered and rewritten. Defaults to
+	// DefaultMaxBody.
+	MaxBody    int64
+
+	// If nil, errors are logged with the log package.
+	ErrorLog   *log.Logger
+
+	once       sync.Once
+	rp         *httputil.ReverseProxy
+}
+
+// The default of ReverseProxy.MaxBody.
+const DefaultMaxBody = 8<<20
+
+func (p *ReverseProxy) prefix() string { return strings.TrimSuffix(p.Prefix,"/") }
+func (p *ReverseProxy) root() string { return strings.TrimSuffix(p.Upstream.Path,"/") }
+
+// maps an upstream path to the prefix. Reports false, if the path is outside the upstream root.
+func (p *ReverseProxy) mapPath(path string) (string,bool) {
+	root := p.root()
+	if path!=root && !strings.HasPrefix(path,root+"/") { return "",false }
+	path = p.prefix()+path[len(root):]
+	if path=="" { path = "/" }
+	return path,true
+}
+
+// maps the upstream URL s (relative to base) to the prefix. Other URLs are left alone.
+func (p *ReverseProxy) mapURL(base *url.URL,s string) string {
+	t := strings.TrimSpace(s)
+	if t=="" || t[0]=='#' { return s }
+	u,e := base.Parse(t)
+	if e!=nil || !strings.EqualFold(u.Host,p.Upstream.Host) || (u.Scheme!="http" && u.Scheme!="https") { return s }
+	path,ok := p.mapPath(u.Path)
+	if !ok { return s }
+	m := &url.URL{Path:path,RawQuery:u.RawQuery,Fragment:u.Fragment}
+	return m.String()
+}
+
+func (p *ReverseProxy) init() {
+	p.rp = &httputil.ReverseProxy{
+		Rewrite:func(pr *httputil.ProxyRequest){
+			path := strings.TrimPrefix(pr.In.URL.Path,p.prefix())
+			pr.Out.URL.Path = path
+			pr.Out.URL.RawPath = ""
+			pr.SetURL(p.Upstream)
+			pr.SetXForwarded()
+			// the transport decompresses the response, if it asked for compression itself.
+			pr.Out.Header.Del("Accept-Encoding")
+		},
+		Transport:p.Transport,
+		ErrorLog:p.ErrorLog,
+		ModifyResponse:p.modify,
+	}
+}
+
+func (p *ReverseProxy) modify(resp *http.Response) error {
+	h := resp.Header
+	for _,k := range []string{"Location","Content-Location"} {
+		if v := h.Get(k); v!="" { h.Set(k,p.mapURL(resp.Request.URL,v)) }
+	}
+	if sc := h.Values("Set-Cookie"); len(sc)>0 {
+		h.Del("Set-Cookie")
+		for _,line := range sc {
+			c,e := http.ParseSetCookie(line)
+			if e!=nil { continue }
+			c.Domain = ""
+			if c.Path!="" {
+				if path,ok := p.mapPath(c.Path); ok {
+					c.Path = path
+				} else {
+					c.Path = p.prefix()+"/"
+				}
+			}
+			// String returns "", if the cookie is invalid.
+			if v := c.String(); v!="" { h.Add("Set-Cookie",v) }
+		}
+	}
+
+	mt,params,_ := mime.ParseMediaType(h.Get("Content-Type"))
+	if mt!="text/html" || h.Get("Content-Encoding")!="" || resp.Request.Method=="HEAD" { return nil }
+	max := p.MaxBody
+	if max<=0 { max = DefaultMaxBody }
+	if resp.ContentLength>max { return nil }
+	b,e := io.ReadAll(io.LimitReader(resp.Body,max+1))
+	if e!=nil { return e }
+	if int64(len(b))>max {
+		// too large: forward it untouched, the part read included.
+		resp.Body = struct{ io.Reader; io.Closer }{io.MultiReader(bytes.NewReader(b),resp.Body),resp.Body}
+		return nil
+	}
+	resp.Body.Close()
+	r,e := charset.NewReader(bytes.NewReader(b),h.Get("Content-Type"))
+	if e!=nil { return e }
+	doc,e := html.Parse(r)
+	if e!=nil { return e }
+	base := htmlscrape.BaseURL(doc,resp.Request.URL)
+	htmlscrape.Walk(doc,htmlscrape.ReplaceURLs(func(s string) string { return p.mapURL(base,s) }))
+	for _,t := range p.Transforms { htmlscrape.Walk(doc,t) }
+	buf := new(bytes.Buffer)
+	if e = html.Render(buf,doc); e!=nil { return e }
+
+	resp.Body = io.NopCloser(buf)
+	resp.ContentLength = int64(buf.Len())
+	h.Set("Content-Length",strconv.Itoa(buf.Len()))
+	if params==nil { params = make(map[string]string) }
+	params["charset"] = "utf-8"
+	h.Set("Content-Type",mime.FormatMediaType(mt,params))
+	// the content is equivalent, but not byte-identical.
+	if et := h.Get("ETag"); et!="" && !strings.HasPrefix(et,"W/") { h.Set("ETag","W/"+et) }
+	return nil
+}
+
+func (p *ReverseProxy) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	p.once.Do(p.init)
+	p.rp.ServeHTTP(resp,req)
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"github.com/maxymania/scrapland/htmlscrape"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func upstream(t *testing.T) *httptest.Server {
+	var us *httptest.Server
+	us = serve(t,func(w http.ResponseWriter,r *http.Request) {
+		switch r.URL.Path {
+		case "/wiki/page":
+			w.Header().Set("Content-Type","text/html; charset=iso-8859-1")
+			w.Header().Set("ETag",`"v1"`)
+			http.SetCookie(w,&http.Cookie{Name:"a",Value:"1",Path:"/wiki/x",Domain:"upstream.example"})
+			http.SetCookie(w,&http.Cookie{Name:"b",Value:"2",Path:"/other"})
+			io.WriteString(w,`<a href="`+us.URL+`/wiki/a?q=1#f">a</a><a href="b">b</a><a href="/other">o</a><a href="http://else.where/wiki/c">c</a><div id="ad">ad</div><p>Gr`+"\xfc\xdf"+`e</p>`)
+		case "/wiki/old":
+			http.Redirect(w,r,us.URL+"/wiki/new",http.StatusFound)
+		case "/wiki/big":
+			w.Header().Set("Content-Type","text/html")
+			io.WriteString(w,`<a href="/wiki/a">a</a>`+strings.Repeat("x",2000))
+		case "/wiki/chunked":
+			w.Header().Set("Content-Type","text/html")
+			io.WriteString(w,`<a href="/wiki/a">a</a>`)
+			w.(http.Flusher).Flush()
+			io.WriteString(w,strings.Repeat("x",2000))
+		case "/wiki/data":
+			w.Header().Set("Content-Type","application/json")
+			io.WriteString(w,`{"href":"/wiki/a"}`)
+		default:
+			http.NotFound(w,r)
+		}
+	})
+	return us
+}
+
+func TestReverseProxy(t *testing.T) {
+	us := upstream(t)
+	u,_ := url.Parse(us.URL+"/wiki/")
+	rp := &ReverseProxy{Upstream:u,Prefix:"/mirror/",Transforms:[]htmlscrape.Transf{htmlscrape.Remove("#ad")},MaxBody:1000}
+	ps := httptest.NewServer(rp)
+	defer ps.Close()
+	client := &http.Client{CheckRedirect:func(*http.Request,[]*http.Request) error { return http.ErrUseLastResponse }}
+	fetch := func(path string) (*http.Response,string) {
+		t.Helper()
+		resp,e := client.Get(ps.URL+path)
+		if e!=nil { t.Fatal(e) }
+		defer resp.Body.Close()
+		b,_ := io.ReadAll(resp.Body)
+		return resp,string(b)
+	}
+
+	resp,body := fetch("/mirror/page")
+	want := `<a href="/mirror/a?q=1#f">a</a><a href="/mirror/b">b</a><a href="/other">o</a><a href="http://else.where/wiki/c">c</a><p>Grüße</p>`
+	if !strings.Contains(body,want) { t.Errorf("got %s, want %s",body,want) }
+	if ct := resp.Header.Get("Content-Type"); ct!="text/html; charset=utf-8" { t.Errorf("Content-Type %s",ct) }
+	if et := resp.Header.Get("ETag"); et!=`W/"v1"` { t.Errorf("ETag %s",et) }
+	cookies := resp.Header.Values("Set-Cookie")
+	if len(cookies)!=2 || cookies[0]!="a=1; Path=/mirror/x" || cookies[1]!="b=2; Path=/mirror/" { t.Errorf("cookies %q",cookies) }
+
+	resp,_ = fetch("/mirror/old")
+	if l := resp.Header.Get("Location"); l!="/mirror/new" { t.Errorf("Location %s",l) }
+
+	// larger than MaxBody: forwarded untouched
+	for _,path := range []string{"/mirror/big","/mirror/chunked"} {
+		_,body = fetch(path)
+		if want := `<a href="/wiki/a">a</a>`+strings.Repeat("x",2000); body!=want { t.Errorf("%s: got %s",path,body) }
+	}
+
+	_,body = fetch("/mirror/data")
+	if body!=`{"href":"/wiki/a"}` { t.Errorf("JSON was modified: %s",body) }
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// A request together with the fragments to extract from its response.
+type Source struct{
+	Request *http.Request
+	Queries []*QueryElement
+}
+
+/*
+ Scraper fetches many Sources in parallel, eg. to fill a container.Page from
+ several sites at once. The number of concurrent fetches can be bounded, both
+ in total and per host. The zero value of Scraper (with a Client) is ready to
+ use and imposes no limits.
+
+ As the Elements are offered as soon as their Source is done, a page can be
+ rendered while Scrape is still running in its own goroutine.
+ */
+type Scraper struct{
+	Client HttpClient
+
+	// If not nil, the Sources are fetched through Fetcher (and its Client)
+	// instead of Client, so identical concurrent requests are collapsed.
+	Fetcher *Fetcher
+
+	// The deadline for a single Source, counting from the call to Fetch (so
+	// the time spent waiting for a free slot is included). After it has been
+	// exceeded, the Elements are offered their Fallback content. 0 means no
+	// deadline.
+	Timeout time.Duration
+
+	// The maximum number of concurrent fetches. 0 means unlimited.
+	MaxConcurrent int
+
+	// The maximum number of concurrent fetches per host. 0 means unlimited.
+	MaxPerHost int
+
+	once  sync.Once
+	mutex sync.Mutex
+	all   chan struct{}
+	hosts map[string]chan struct{}
+}
+
+func (s *Scraper) init() {
+	if s.MaxConcurrent>0 { s.all = make(chan struct{},s.MaxConcurrent) }
+	s.hosts = make(map[string]chan struct{})
+}
+
+func (s *Scraper) host(h string) chan struct{} {
+	if s.MaxPerHost<=0 { return nil }
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	c,ok := s.hosts[h]
+	if !ok {
+		c = make(chan struct{},s.MaxPerHost)
+		s.hosts[h] = c
+	}
+	return c
+}
+
+func acquire(ctx context.Context,c chan struct{}) error {
+	if c==nil { return nil }
+	select {
+	case c <- struct{}{}:
+		return nil
+	case <- ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func release(c chan struct{}) {
+	if c!=nil { <- c }
+}
+
+/*
+ Fetches a single Source (see GetFragmentsContext), waiting for a free slot
+ if the concurrency limits are reached.
+ */
+func (s *Scraper) Fetch(ctx context.Context, r *http.Request, qs []*QueryElement) error {
+	s.once.Do(s.init)
+	if s.Timeout>0 {
+		var cancel context.CancelFunc
+		ctx,cancel = context.WithTimeout(ctx,s.Timeout)
+		defer cancel()
+	}
+	// the host slot comes first: Requests waiting for a busy host must not
+	// hold global slots, that the other hosts could use.
+	h := s.host(r.URL.Host)
+	if e := acquire(ctx,h); e!=nil {
+		return fragments(r,qs,fetched{e:e})
+	}
+	defer release(h)
+	if e := acquire(ctx,s.all); e!=nil {
+		return fragments(r,qs,fetched{e:e})
+	}
+	defer release(s.all)
+	if s.Fetcher!=nil { return s.Fetcher.GetFragments(ctx,r,qs) }
+	return GetFragmentsContext(ctx,s.Client,r,qs)
+}
+
+/*
+ Fetches all Sources in parallel and waits until every one is done. The
+ returned error joins the errors (*Report) of all failed Sources.
+ */
+func (s *Scraper) Scrape(ctx context.Context, srcs ...*Source) error {
+	errs := make([]error,len(srcs))
+	wg := new(sync.WaitGroup)
+	wg.Add(len(srcs))
+	for i,src := range srcs {
+		go func(i int,src *Source){
+			defer wg.Done()
+			errs[i] = s.Fetch(ctx,src.Request,src.Queries)
+		}(i,src)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
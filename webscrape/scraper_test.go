@@ -0,0 +1,110 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"github.com/maxymania/scrapland/container"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetFragmentsContextDeadline(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	s := serve(t,func(w http.ResponseWriter,r *http.Request) {
+		select {
+		case <- block:
+		case <- r.Context().Done():
+		}
+	})
+	ctx,cancel := context.WithTimeout(context.Background(),50*time.Millisecond)
+	defer cancel()
+	q := &QueryElement{Selectors:[]string{"p"},Element:container.NewElement(),Fallback:"slow"}
+	start := time.Now()
+	e := GetFragmentsContext(ctx,http.DefaultClient,get(t,s.URL),[]*QueryElement{q})
+	if !errors.Is(e,context.DeadlineExceeded) { t.Errorf("got %v, want DeadlineExceeded",e) }
+	if d := time.Since(start); d>time.Second { t.Errorf("took %v",d) }
+	if q.Element.Get()!="slow" { t.Errorf("got %q, want the Fallback",q.Element.Get()) }
+}
+
+// a server, that records the maximum number of concurrent requests.
+type gauge struct{
+	cur,max atomic.Int32
+	delay   time.Duration
+}
+
+func (g *gauge) ServeHTTP(w http.ResponseWriter,r *http.Request) {
+	n := g.cur.Add(1)
+	for {
+		m := g.max.Load()
+		if n<=m || g.max.CompareAndSwap(m,n) { break }
+	}
+	time.Sleep(g.delay)
+	g.cur.Add(-1)
+	page(`<p>ok</p>`)(w,r)
+}
+
+func sources(t *testing.T,u string,n int) []*Source {
+	l := make([]*Source,n)
+	for i := range l {
+		l[i] = &Source{get(t,u),[]*QueryElement{{Selectors:[]string{"p"},Element:container.NewElement()}}}
+	}
+	return l
+}
+
+func TestScraperLimits(t *testing.T) {
+	ga,gb := &gauge{delay:20*time.Millisecond},&gauge{delay:20*time.Millisecond}
+	a,b := serve(t,ga.ServeHTTP),serve(t,gb.ServeHTTP)
+	s := &Scraper{Client:http.DefaultClient,MaxConcurrent:3,MaxPerHost:2}
+	srcs := append(sources(t,a.URL,6),sources(t,b.URL,6)...)
+	if e := s.Scrape(context.Background(),srcs...); e!=nil { t.Fatal(e) }
+	for _,src := range srcs {
+		if got := src.Queries[0].Element.Get(); got!="ok" { t.Errorf("got %q",got) }
+	}
+	if ga.max.Load()>2 || gb.max.Load()>2 { t.Errorf("per host: %d and %d concurrent requests, want at most 2",ga.max.Load(),gb.max.Load()) }
+	if ga.max.Load()+gb.max.Load()<3 { t.Errorf("the hosts were not fetched in parallel") }
+}
+
+// Requests waiting for a busy host must not keep the other hosts from being fetched.
+func TestScraperNoStarvation(t *testing.T) {
+	release := make(chan struct{})
+	var once sync.Once
+	defer once.Do(func(){ close(release) })
+	slow := serve(t,func(w http.ResponseWriter,r *http.Request) {
+		<- release
+		page(`<p>ok</p>`)(w,r)
+	})
+	fast := serve(t,page(`<p>ok</p>`))
+	s := &Scraper{Client:http.DefaultClient,MaxConcurrent:2,MaxPerHost:1}
+
+	// one request to the slow host is running, two more are waiting for it.
+	for _,src := range sources(t,slow.URL,3) {
+		go s.Fetch(context.Background(),src.Request,src.Queries)
+	}
+	time.Sleep(50*time.Millisecond)
+	done := make(chan error,1)
+	go func(){ done <- s.Scrape(context.Background(),sources(t,fast.URL,1)...) }()
+	select {
+	case e := <- done:
+		if e!=nil { t.Error(e) }
+	case <- time.After(2*time.Second):
+		t.Fatal("the fast host starved behind the slow one")
+	}
+	once.Do(func(){ close(release) })
+}
@@ -0,0 +1,304 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"github.com/maxymania/scrapland/htmlscrape"
+	"golang.org/x/net/html"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// The login form could not be found or the site did not accept the login.
+	ErrLoginFailed = errors.New("webscrape: login failed")
+
+	// Returned by Sessions.Get, if the request has no session key.
+	ErrNoSession = errors.New("webscrape: no session key")
+)
+
+/*
+ Describes, how to log in to a site: The page at URL is fetched, the form is
+ looked up, its fields are filled and the form is submitted.
+ */
+type Login struct{
+	// The URL of the page, that contains the login form.
+	URL    string
+
+	// The selectors of the form (see htmlscrape.LurkFor). If empty, the
+	// first form of the page is used.
+	Form   []string
+
+	// The values to fill in, eg. {"user":"alice","password":"secret"} (see
+	// htmlscrape.Form.Set). The other fields of the form keep their default
+	// values, so hidden CSRF fields are submitted unchanged.
+	Fields map[string]string
+
+	// CSRF tokens, that are not part of the form: Maps a field name to the
+	// selectors of the element, that holds the token in its "content" or
+	// "value" attribute (or as text), eg. {"_csrf":{"#csrf-token"}}.
+	Tokens map[string][]string
+
+	// If set, a response is considered to be the login page, if it is HTML
+	// and contains an element matching these selectors. Otherwise, a
+	// response is the login page, if it comes from URL (eg. after a
+	// redirect) and contains the login form (see Form, or a form with a
+	// password field, if Form is empty).
+	Marker []string
+
+	// If set, it replaces the built-in login page detection.
+	IsLoginPage func(resp *http.Response) bool
+}
+
+// reports, whether resp is the login page, ie. whether the session expired.
+func (l *Login) isLoginPage(resp *http.Response) bool {
+	if l.IsLoginPage!=nil { return l.IsLoginPage(resp) }
+	if resp.StatusCode==http.StatusUnauthorized { return true }
+	if resp.Request==nil { return false }
+	// a site may use the login URL as its landing page, so the URL alone
+	// does not tell, whether the session expired.
+	atLogin := false
+	if lu,e := url.Parse(l.URL); e==nil {
+		u := resp.Request.URL
+		atLogin = u.Host==lu.Host && u.Path==lu.Path
+	}
+	if len(l.Marker)==0 && !atLogin { return false }
+	p := peekHTML(resp)
+	if p==nil { return false }
+	if len(l.Marker)>0 { return lurk(p,l.Marker)!=nil }
+	if len(l.Form)>0 { return lurk(p,l.Form)!=nil }
+	for _,in := range htmlscrape.FindAll(p,"input") {
+		if strings.EqualFold(htmlscrape.Attr(in,"type"),"password") { return true }
+	}
+	return false
+}
+
+// parses the body of resp, if it is HTML, and puts a copy of the body in its place.
+func peekHTML(resp *http.Response) *html.Node {
+	if !strings.Contains(resp.Header.Get("Content-Type"),"html") { return nil }
+	b,e := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+	if e!=nil { return nil }
+	d,e := ParseHTML(bytes.NewReader(b),resp.Header.Get("Content-Type"),resp.Request.URL)
+	if e!=nil { return nil }
+	return d.(HTMLDocument).Node()
+}
+
+// applies htmlscrape.LurkFor with the selectors sels in turn.
+func lurk(n *html.Node,sels []string) *html.Node {
+	for _,sel := range sels {
+		if n = htmlscrape.LurkFor(n,sel); n==nil { return nil }
+	}
+	return n
+}
+
+/*
+ A Session is an HttpClient, that keeps cookies and logs in to a site, when
+ needed. If a response looks like the login page (the session expired), the
+ Session logs in again and repeats the request once.
+ */
+type Session struct{
+	// The underlying client. Its Jar keeps the session cookies.
+	Client *http.Client
+
+	// The login step, nil if the site needs no login.
+	Login  *Login
+
+	mutex  sync.Mutex
+	gen    int  // incremented on every login
+	valid  bool // logged in
+	used   atomic.Int64 // the time of the last use (Unix nanoseconds)
+}
+
+func (s *Session) touch() { s.used.Store(time.Now().UnixNano()) }
+
+// reports, whether s has not been used for d.
+func (s *Session) idle(d time.Duration) bool {
+	return time.Since(time.Unix(0,s.used.Load()))>d
+}
+
+/*
+ Creates a Session. If jar is nil, an in-memory jar is used (see FileJar for
+ a persistent one). The Transport of s.Client may be set afterwards.
+ */
+func NewSession(jar http.CookieJar, l *Login) *Session {
+	if jar==nil { jar,_ = cookiejar.New(nil) }
+	return &Session{Client:&http.Client{Jar:jar},Login:l}
+}
+
+/*
+ Logs in, using the Login description. Normally, it is not necessary to
+ call LogIn, as Do logs in on demand.
+ */
+func (s *Session) LogIn(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.logIn(ctx)
+}
+
+// must be called with s.mutex held.
+func (s *Session) logIn(ctx context.Context) error {
+	s.valid = false
+	l := s.Login
+	r,e := http.NewRequestWithContext(ctx,"GET",l.URL,nil)
+	if e!=nil { return e }
+	form,base,e := GetForm(s.Client,r,l.Form...)
+	if e==ErrNotFound { return ErrLoginFailed }
+	if e!=nil { return e }
+
+	root := form.Node
+	for root.Parent!=nil { root = root.Parent }
+	set := func(k,v string) {
+		if form.Set(k,v)==htmlscrape.ErrNoField { form.Add(k,v) }
+	}
+	for k,sels := range l.Tokens {
+		t := lurk(root,sels)
+		if t==nil { return ErrLoginFailed }
+		switch {
+		case htmlscrape.HasAttr(t,"content"): set(k,htmlscrape.Attr(t,"content"))
+		case htmlscrape.HasAttr(t,"value"): set(k,htmlscrape.Attr(t,"value"))
+		default: set(k,strings.TrimSpace(htmlscrape.ExtractText(t)))
+		}
+	}
+	for k,v := range l.Fields { set(k,v) }
+
+	if r,e = form.Request(base); e!=nil { return e }
+	r = r.WithContext(ctx)
+	resp,e := s.Client.Do(r)
+	if e!=nil { return e }
+	defer resp.Body.Close()
+	if resp.StatusCode<200 || resp.StatusCode>299 || l.isLoginPage(resp) { return ErrLoginFailed }
+	s.gen++
+	s.valid = true
+	return nil
+}
+
+// logs in, unless another request has done so since generation gen.
+func (s *Session) ensure(ctx context.Context,gen int) (int,error) {
+	s.touch()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.Login==nil || (s.valid && s.gen!=gen) { return s.gen,nil }
+	if e := s.logIn(ctx); e!=nil { return s.gen,e }
+	return s.gen,nil
+}
+
+func (s *Session) Do(req *http.Request) (*http.Response,error) {
+	s.touch()
+	s.mutex.Lock()
+	gen,valid := s.gen,s.valid
+	s.mutex.Unlock()
+	if !valid && s.Login!=nil {
+		var e error
+		if gen,e = s.ensure(req.Context(),gen); e!=nil { return nil,e }
+	}
+	// http.Client adds the cookies of its Jar to the request, so a copy is sent.
+	resp,err := s.Client.Do(req.Clone(req.Context()))
+	if err!=nil || s.Login==nil || !s.Login.isLoginPage(resp) { return resp,err }
+
+	// the session expired: log in again and repeat the request.
+	if req.Body!=nil && req.Body!=http.NoBody && req.GetBody==nil { return resp,err }
+	resp.Body.Close()
+	if _,e := s.ensure(req.Context(),gen); e!=nil { return nil,e }
+	req = req.Clone(req.Context())
+	if req.GetBody!=nil {
+		body,e := req.GetBody()
+		if e!=nil { return nil,e }
+		req.Body = body
+	}
+	resp,err = s.Client.Do(req)
+	if err==nil && s.Login.isLoginPage(resp) {
+		resp.Body.Close()
+		return nil,ErrLoginFailed
+	}
+	return resp,err
+}
+
+/*
+ Sessions keeps a Session per user of the own site, eg. to scrape an
+ intranet with the credentials of the user, that requested the page.
+ */
+type Sessions struct{
+	// Derives the session key from the incoming request, eg. the user name
+	// or the value of a session cookie (see CookieKey). An empty key means,
+	// that the request has no session.
+	Key     func(r *http.Request) string
+
+	// Creates the Session for a key.
+	New     func(key string) (*Session,error)
+
+	// Sessions, that have not been used for MaxIdle, are discarded. 0 means never.
+	MaxIdle time.Duration
+
+	mutex   sync.Mutex
+	m       map[string]*Session
+}
+
+// Uses the value of the cookie name as session key.
+func CookieKey(name string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		c,e := r.Cookie(name)
+		if e!=nil { return "" }
+		return c.Value
+	}
+}
+
+/*
+ Returns the Session for the incoming request r, creating it if necessary.
+ New should not log in (Session.Do does so on demand), as it is called with
+ the lock of ss held.
+ */
+func (ss *Sessions) Get(r *http.Request) (*Session,error) {
+	k := ss.Key(r)
+	if k=="" { return nil,ErrNoSession }
+	s,e := ss.get(k)
+	if e!=nil { return nil,e }
+	s.touch()
+	return s,nil
+}
+
+func (ss *Sessions) get(k string) (*Session,error) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	if ss.m==nil { ss.m = make(map[string]*Session) }
+	if ss.MaxIdle>0 {
+		// the sessions are not locked, as they may be logging in.
+		for key,s := range ss.m {
+			if s.idle(ss.MaxIdle) { delete(ss.m,key) }
+		}
+	}
+	if s,ok := ss.m[k]; ok { return s,nil }
+	s,e := ss.New(k)
+	if e!=nil { return nil,e }
+	s.touch()
+	ss.m[k] = s
+	return s,nil
+}
+
+// Discards the Session of the incoming request r, eg. on logout.
+func (ss *Sessions) Remove(r *http.Request) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	delete(ss.m,ss.Key(r))
+}
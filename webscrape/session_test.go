@@ -0,0 +1,241 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"github.com/maxymania/scrapland/container"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// a site with a login form, a CSRF token and a session cookie.
+type loginSite struct{
+	mutex    sync.Mutex
+	logins   int
+	sessions map[string]bool
+	landing  bool // the login page is the landing page "/"
+}
+
+func (s *loginSite) loggedIn(r *http.Request) bool {
+	c,e := r.Cookie("sid")
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return e==nil && s.sessions[c.Value]
+}
+
+// ends all sessions.
+func (s *loginSite) expire() {
+	s.mutex.Lock()
+	s.sessions = nil
+	s.mutex.Unlock()
+}
+
+const loginForm = `<meta name="csrf" content="meta-token"><form id="login" method="post"><input type="hidden" name="csrf" value="form-token"><input name="user"><input type="password" name="password"><input type="submit"></form>`
+
+func (s *loginSite) ServeHTTP(w http.ResponseWriter,r *http.Request) {
+	login := "/login"
+	if s.landing { login = "/" }
+	w.Header().Set("Content-Type","text/html")
+	switch {
+	case r.URL.Path==login && r.Method=="POST":
+		if r.FormValue("csrf")!="form-token" || r.FormValue("meta")!="meta-token" || r.FormValue("password")!="secret" {
+			io.WriteString(w,loginForm)
+			return
+		}
+		s.mutex.Lock()
+		s.logins++
+		sid := fmt.Sprint("s",s.logins)
+		if s.sessions==nil { s.sessions = make(map[string]bool) }
+		s.sessions[sid] = true
+		s.mutex.Unlock()
+		http.SetCookie(w,&http.Cookie{Name:"sid",Value:sid,Path:"/"})
+		http.Redirect(w,r,"/private",http.StatusSeeOther)
+	case r.URL.Path==login:
+		if s.loggedIn(r) {
+			io.WriteString(w,`<p id="x">welcome</p>`)
+			return
+		}
+		io.WriteString(w,loginForm)
+	case !s.loggedIn(r):
+		http.Redirect(w,r,login,http.StatusFound)
+	default:
+		b,_ := io.ReadAll(r.Body)
+		fmt.Fprintf(w,`<p id="x">private %s</p>`,b)
+	}
+}
+
+func (s *loginSite) count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.logins
+}
+
+func newSession(u,path,password string) *Session {
+	return NewSession(nil,&Login{
+		URL:u+path,
+		Form:[]string{"#login"},
+		Fields:map[string]string{"user":"alice","password":password},
+		Tokens:map[string][]string{"meta":{"meta"}},
+	})
+}
+
+// queries #x of the page u with the Session s.
+func sessionQuery(t *testing.T,s *Session,u string) (string,error) {
+	t.Helper()
+	q := &QueryElement{Selectors:[]string{"#x"},Element:container.NewElement()}
+	e := GetFragments(s,get(t,u),[]*QueryElement{q})
+	return q.Element.Get(),e
+}
+
+func TestSession(t *testing.T) {
+	site := new(loginSite)
+	ts := serve(t,site.ServeHTTP)
+	s := newSession(ts.URL,"/login","secret")
+	if got,e := sessionQuery(t,s,ts.URL+"/private"); e!=nil || got!="private " { t.Fatalf("got %q, %v",got,e) }
+	sessionQuery(t,s,ts.URL+"/private")
+	if n := site.count(); n!=1 { t.Errorf("%d logins, want 1",n) }
+
+	// the session expires: log in again and repeat the request, with its body
+	site.expire()
+	r,_ := http.NewRequest("POST",ts.URL+"/private",strings.NewReader("body"))
+	resp,e := s.Do(r)
+	if e!=nil { t.Fatal(e) }
+	b,_ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(b),"private body") { t.Errorf("got %q",b) }
+	if n := site.count(); n!=2 { t.Errorf("%d logins, want 2",n) }
+
+	s = newSession(ts.URL,"/login","wrong")
+	if _,e := s.Do(get(t,ts.URL+"/private")); e!=ErrLoginFailed { t.Errorf("got %v, want ErrLoginFailed",e) }
+	s.Login.Form = []string{"#missing"}
+	if e := s.LogIn(get(t,ts.URL).Context()); e!=ErrLoginFailed { t.Errorf("got %v, want ErrLoginFailed",e) }
+}
+
+func TestSessionLandingPage(t *testing.T) {
+	// the login URL is the landing page, so it is not the login page, once logged in.
+	site := &loginSite{landing:true}
+	ts := serve(t,site.ServeHTTP)
+	s := newSession(ts.URL,"/","secret")
+	for i := 0; i<3; i++ {
+		if got,e := sessionQuery(t,s,ts.URL+"/"); e!=nil || got!="welcome" { t.Fatalf("got %q, %v",got,e) }
+	}
+	if n := site.count(); n!=1 { t.Errorf("%d logins, want 1",n) }
+}
+
+func TestSessions(t *testing.T) {
+	created := 0
+	ss := &Sessions{
+		Key:CookieKey("user"),
+		New:func(k string) (*Session,error) {
+			created++
+			return NewSession(nil,nil),nil
+		},
+		MaxIdle:20*time.Millisecond,
+	}
+	req := func(user string) *http.Request {
+		r,_ := http.NewRequest("GET","http://own.site/",nil)
+		if user!="" { r.AddCookie(&http.Cookie{Name:"user",Value:user}) }
+		return r
+	}
+	if _,e := ss.Get(req("")); e!=ErrNoSession { t.Errorf("got %v, want ErrNoSession",e) }
+	a,_ := ss.Get(req("alice"))
+	b,_ := ss.Get(req("bob"))
+	if a2,_ := ss.Get(req("alice")); a2!=a || a==b { t.Errorf("sessions are not kept per key") }
+
+	// Get does not wait for a session, that is logging in.
+	a.mutex.Lock()
+	done := make(chan struct{})
+	go func(){
+		time.Sleep(30*time.Millisecond)
+		ss.Get(req("carol"))
+		close(done)
+	}()
+	select {
+	case <- done:
+	case <- time.After(time.Second):
+		t.Fatal("Get blocked on a locked session")
+	}
+	a.mutex.Unlock()
+	// alice and bob have been idle for MaxIdle
+	if a2,_ := ss.Get(req("alice")); a2==a { t.Errorf("an idle session was kept") }
+	ss.Remove(req("alice"))
+	ss.Get(req("alice"))
+	if created!=5 { t.Errorf("%d sessions created, want 5",created) }
+}
+
+func TestFileJar(t *testing.T) {
+	name := filepath.Join(t.TempDir(),"jar.json")
+	j,e := NewFileJar(name)
+	if e!=nil { t.Fatal(e) }
+	u,_ := url.Parse("http://example.com/a/b")
+	j.SetCookies(u,[]*http.Cookie{
+		{Name:"a",Value:"1",Path:"/"},
+		{Name:"b",Value:"2",Path:"/",Expires:time.Now().Add(time.Hour)},
+		{Name:"c",Value:"3",Path:"/",Expires:time.Now().Add(time.Second)},
+	})
+	j.SetCookies(u,[]*http.Cookie{{Name:"a",Value:"4",Path:"/"}})
+	if e = j.Save(); e!=nil { t.Fatal(e) }
+
+	time.Sleep(1100*time.Millisecond)
+	j,e = NewFileJar(name)
+	if e!=nil { t.Fatal(e) }
+	var got []string
+	for _,c := range j.Cookies(u) { got = append(got,c.Name+"="+c.Value) }
+	if strings.Join(got," ")!="a=4 b=2" { t.Errorf("got %v",got) }
+
+	j,e = NewFileJar("")
+	if e!=nil || j.Save()!=nil { t.Errorf("an in-memory jar failed: %v",e) }
+}
+
+func TestFileJarRestart(t *testing.T) {
+	name := filepath.Join(t.TempDir(),"jar.json")
+	j,e := NewFileJar(name)
+	if e!=nil { t.Fatal(e) }
+	ab,_ := url.Parse("http://example.com/a/b")
+	j.SetCookies(ab,[]*http.Cookie{
+		{Name:"short",Value:"1",MaxAge:1},
+		{Name:"long",Value:"2",MaxAge:3600},
+		{Name:"gone",Value:"3"},
+		{Name:"dir",Value:"4"},
+		{Name:"dom",Value:"5",Domain:".Example.com",Path:"/"},
+	})
+	j.SetCookies(ab,[]*http.Cookie{{Name:"gone",MaxAge:-1}})
+	if e = j.Save(); e!=nil { t.Fatal(e) }
+
+	time.Sleep(1100*time.Millisecond)
+	j,e = NewFileJar(name)
+	if e!=nil { t.Fatal(e) }
+	names := func(u string) string {
+		pu,_ := url.Parse(u)
+		var l []string
+		for _,c := range j.Cookies(pu) { l = append(l,c.Name) }
+		slices.Sort(l)
+		return strings.Join(l," ")
+	}
+	for u,want := range map[string]string{
+		"http://example.com/a/x":"dir dom long",
+		"http://example.com/other":"dom",
+		"http://www.example.com/a/x":"dom",
+	} {
+		if got := names(u); got!=want { t.Errorf("%s: got %q, want %q",u,got,want) }
+	}
+}
@@ -0,0 +1,304 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"github.com/maxymania/scrapland/htmlscrape"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// the "special" elements of the HTML parser, that limit the implied end tags.
+var special = atoms(`address applet area article aside base basefont bgsound blockquote body br button
+	caption center col colgroup dd details dir div dl dt embed fieldset figcaption figure footer form
+	frame frameset h1 h2 h3 h4 h5 h6 head header hgroup hr html iframe img input keygen li link listing
+	main marquee menu meta nav noembed noframes noscript object ol p param plaintext pre script search
+	section select source style summary table tbody td template textarea tfoot th thead title tr track
+	ul wbr xmp`)
+
+// the start tags, that close an open p element.
+var closesP = atoms(`address article aside blockquote center details dialog dir div dl fieldset
+	figcaption figure footer form h1 h2 h3 h4 h5 h6 header hgroup hr listing main menu nav ol p
+	plaintext pre search section summary table ul xmp li dd dt`)
+
+var (
+	buttonScope = atoms(`applet caption html table td th marquee object template button`)
+	tableScope  = atoms(`html table template`)
+	headings    = atoms(`h1 h2 h3 h4 h5 h6`)
+)
+
+func atoms(names string) map[string]bool {
+	m := make(map[string]bool)
+	for _,n := range strings.Fields(names) { m[n] = true }
+	return m
+}
+
+// pops the innermost open element in names, unless an element in scope comes first.
+func closeOpen(stack []string,names,scope map[string]bool) []string {
+	for i := len(stack)-1; i>=0; i-- {
+		if names[stack[i]] { return stack[:i] }
+		if scope[stack[i]] { break }
+	}
+	return stack
+}
+
+/*
+ pops the elements, that the start tag name closes implicitly, following
+ the "in body", "in table" and "in select" rules of the HTML parser, eg.
+ <p> is closed by <div> and <td> by the next <td> or <tr>.
+ */
+func implyEnd(stack []string,name string) []string {
+	top := func() string {
+		if len(stack)==0 { return "" }
+		return stack[len(stack)-1]
+	}
+	switch name {
+	case "li","dd","dt":
+		items := map[string]bool{name:true}
+		if name!="li" { items = map[string]bool{"dd":true,"dt":true} }
+		for i := len(stack)-1; i>=0; i-- {
+			if items[stack[i]] {
+				stack = stack[:i]
+				break
+			}
+			if special[stack[i]] && stack[i]!="address" && stack[i]!="div" && stack[i]!="p" { break }
+		}
+	case "option":
+		if top()=="option" { stack = stack[:len(stack)-1] }
+	case "optgroup":
+		if top()=="option" { stack = stack[:len(stack)-1] }
+		if top()=="optgroup" { stack = stack[:len(stack)-1] }
+	case "td","th":
+		stack = closeOpen(stack,map[string]bool{"td":true,"th":true},tableScope)
+	case "tr":
+		stack = closeOpen(stack,map[string]bool{"td":true,"th":true},tableScope)
+		stack = closeOpen(stack,map[string]bool{"tr":true},tableScope)
+	case "tbody","thead","tfoot":
+		stack = closeOpen(stack,map[string]bool{"td":true,"th":true},tableScope)
+		stack = closeOpen(stack,map[string]bool{"tr":true},tableScope)
+		stack = closeOpen(stack,map[string]bool{"tbody":true,"thead":true,"tfoot":true},tableScope)
+	case "a":
+		stack = closeOpen(stack,map[string]bool{"a":true},special)
+	}
+	if closesP[name] { stack = closeOpen(stack,map[string]bool{"p":true},buttonScope) }
+	if headings[name] && headings[top()] { stack = stack[:len(stack)-1] }
+	return stack
+}
+
+// reports, whether sel is supported by the streaming mode.
+func streamable(sel string) bool {
+	return sel!="" && sel!="*" && sel[0]!='?'
+}
+
+// like htmlscrape.LurkFor, for the current start tag of a tokenizer.
+func matchToken(name string,attr []html.Attribute,sel string) bool {
+	return htmlscrape.Match(&html.Node{Type:html.ElementNode,Data:name,Attr:attr},sel)
+}
+
+// the state of a query during streaming.
+type streamQuery struct{
+	q      *QueryElement
+	stage  int          // the number of selectors matched
+	depth  []int        // the depth of the elements matched by the selectors
+	parent string       // the parent of the captured element
+	buf    bytes.Buffer // the raw captured element
+	done   bool
+	found  bool
+}
+
+type streamDocument struct{
+	base *url.URL
+	qs   map[*QueryElement]*streamQuery
+}
+
+func (d *streamDocument) Query(q *QueryElement) (string,error) {
+	sq := d.qs[q]
+	if sq==nil || !sq.found { return "",ErrNotFound }
+	ctx := &html.Node{Type:html.ElementNode,Data:sq.parent,DataAtom:atom.Lookup([]byte(sq.parent))}
+	nn,e := html.ParseFragment(bytes.NewReader(sq.buf.Bytes()),ctx)
+	if e!=nil { return "",e }
+	for _,n := range nn {
+		if n.Type==html.ElementNode { return renderHTML(q,n,d.base) }
+	}
+	return "",ErrNotFound
+}
+
+/*
+ Extracts the queries qs from the HTML document in body with a tokenizer,
+ without building the DOM. It returns, once every query is either satisfied
+ or impossible to satisfy, so the rest of the document is not read.
+ */
+func streamHTML(body io.Reader,contentType string,u *url.URL,qs []*QueryElement) (Document,error) {
+	r,e := charset.NewReader(body,contentType)
+	if e!=nil { return nil,e }
+	d := &streamDocument{base:u,qs:make(map[*QueryElement]*streamQuery)}
+	var active []*streamQuery
+	for _,q := range qs {
+		sq := &streamQuery{q:q}
+		d.qs[q] = sq
+		active = append(active,sq)
+	}
+	var stack []string
+	// ends the queries, whose element matched last is no longer open.
+	closed := func(depth int) {
+		for _,sq := range active {
+			if sq.stage>0 && sq.depth[sq.stage-1]>depth {
+				sq.found = sq.buf.Len()>0
+				sq.done = true
+			}
+		}
+	}
+	z := html.NewTokenizer(r)
+	for len(active)>0 {
+		tt := z.Next()
+		if tt==html.ErrorToken {
+			if z.Err()!=io.EOF { return nil,z.Err() }
+			// the document ended within a captured element.
+			for _,sq := range active { sq.found = sq.buf.Len()>0 }
+			break
+		}
+		raw := z.Raw()
+		for _,sq := range active {
+			if sq.buf.Len()>0 { sq.buf.Write(raw) }
+		}
+		switch tt {
+		case html.StartTagToken,html.SelfClosingTagToken:
+			t := z.Token()
+			if t.DataAtom==atom.Base && d.base==u {
+				for _,a := range t.Attr {
+					if a.Key!="href" { continue }
+					if b,e := u.Parse(a.Val); e==nil { d.base = b }
+				}
+			}
+			stack = implyEnd(stack,t.Data)
+			closed(len(stack))
+			// like the parser, honour "/>" only in foreign content (svg and math).
+			void := isVoid(t.DataAtom) || (tt==html.SelfClosingTagToken && foreign(stack,t.DataAtom))
+			parent := ""
+			if len(stack)>0 { parent = stack[len(stack)-1] }
+			if !void { stack = append(stack,t.Data) }
+			for _,sq := range active {
+				if sq.done || sq.buf.Len()>0 { continue }
+				sels := sq.q.Selectors
+				before := sq.stage
+				// like LurkFor, an element may match several selectors in turn.
+				for sq.stage<len(sels) && matchToken(t.Data,t.Attr,sels[sq.stage]) {
+					sq.stage++
+					sq.depth = append(sq.depth,len(stack))
+				}
+				if sq.stage<len(sels) {
+					// a void element has no descendants to match the rest.
+					if void && sq.stage>before { sq.done = true }
+					continue
+				}
+				sq.parent = parent
+				sq.buf.Write(raw)
+				if void { sq.done,sq.found = true,true }
+			}
+		case html.EndTagToken:
+			name,_ := z.TagName()
+			i := len(stack)-1
+			for i>=0 && stack[i]!=string(name) { i-- }
+			if i<0 { break }
+			stack = stack[:i]
+			closed(len(stack))
+		}
+		n := 0
+		for _,sq := range active {
+			if !sq.done {
+				active[n] = sq
+				n++
+			}
+		}
+		active = active[:n]
+	}
+	return d,nil
+}
+
+// reports, whether an element a, opened within stack, is in svg or math.
+func foreign(stack []string,a atom.Atom) bool {
+	if a==atom.Svg || a==atom.Math { return true }
+	for i := len(stack)-1; i>=0; i-- {
+		switch stack[i] {
+		case "svg","math": return true
+		case "foreignobject","desc","title","annotation-xml": return false
+		}
+	}
+	return false
+}
+
+func isVoid(a atom.Atom) bool {
+	switch a {
+	case atom.Area,atom.Base,atom.Br,atom.Col,atom.Embed,atom.Hr,atom.Img,atom.Input,
+		atom.Link,atom.Meta,atom.Source,atom.Track,atom.Wbr:
+		return true
+	}
+	return false
+}
+
+func fetchStream(hc HttpClient, r *http.Request, qs []*QueryElement) fetched {
+	resp,e := hc.Do(r)
+	if e!=nil { return fetched{e:e} }
+	// closing the body early makes the transport drop the connection.
+	defer resp.Body.Close()
+	if resp.StatusCode<200 || resp.StatusCode>299 {
+		return fetched{e:&StatusError{resp.StatusCode,resp.Status}}
+	}
+	u := r.URL
+	if resp.Request!=nil { u = resp.Request.URL }
+	ct := resp.Header.Get("Content-Type")
+	mt,_,_ := mime.ParseMediaType(ct)
+	stream := mt=="" || mt=="text/html" || mt=="application/xhtml+xml"
+	for _,q := range qs {
+		if q.Article || len(q.Selectors)==0 { stream = false }
+		for _,s := range q.Selectors {
+			if !streamable(s) { stream = false }
+		}
+	}
+	if !stream {
+		d,e := Parse(resp.Body,ct,u)
+		return fetched{d,e}
+	}
+	d,e := streamHTML(resp.Body,ct,u,qs)
+	return fetched{d,e}
+}
+
+/*
+ Like GetFragmentsContext, but the HTML document is processed as a stream of
+ tokens instead of being parsed into a DOM. Once every query has found its
+ element (or cannot find it anymore), the connection is closed, so only the
+ beginning of a large page is downloaded, if the elements are near its top.
+
+ The streaming mode supports the selectors "tag", ".class" and "#id" (but
+ not "", "*" and "?..."). Like with LurkFor, each selector finds the first
+ match within the element found by the previous one. If a query uses an
+ unsupported selector or Article, or the response is not HTML, the whole
+ document is parsed like in GetFragments.
+ */
+func StreamFragments(ctx context.Context, hc HttpClient, r *http.Request, qs []*QueryElement) error {
+	if ctx.Done()==nil {
+		return fragments(r,qs,fetchStream(hc,r,qs))
+	}
+	r = r.WithContext(ctx)
+	return fragments(r,qs,startFunc(func() fetched { return fetchStream(hc,r,qs) }).await(ctx))
+}
+
@@ -0,0 +1,141 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"github.com/maxymania/scrapland/container"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the documents and selectors, that StreamFragments must answer like GetFragments.
+var streamTests = []struct{
+	doc  string
+	sels []string
+}{
+	{`<div id="a"><p class="x y">one</p></div><p class="x">two</p>`,[]string{".x"}},
+	{`<div id="a"><p class="x y">one</p></div><p class="x">two</p>`,[]string{"#a","p"}},
+	{`<div><span>a</span></div><div id="b"><span>b</span></div>`,[]string{"div","span"}},
+	{`<p>intro<div><span>x</span></div>`,[]string{"p","span"}},
+	{`<p>intro<div id="d">x</div>`,[]string{"p"}},
+	{`<ul><li><p>a<li id="b">b<li>c</ul><span>after</span>`,[]string{"li"}},
+	{`<ul><li>a<li><b>b</b></ul>`,[]string{"li","b"}},
+	{`<ul><li>a<ul><li>nested</ul><li id="x">b</ul>`,[]string{"ul","#x"}},
+	{`<dl><dt>t<dd>d<dt id="x">u</dl>`,[]string{"dt","#x"}},
+	{`<table><tr><td>1<td><b>2</b><tr><td>3</table>`,[]string{"td","b"}},
+	{`<table><tr><td>1<td id="x">2<tr><td>3</table>`,[]string{"#x"}},
+	{`<table><tr><td>1</td></tr><tr id="r"><td>2<tbody><tr><td>3</table>`,[]string{"#r","td"}},
+	{`<select><option>a<option id="o">b<optgroup><option>c</select>`,[]string{"#o"}},
+	{`<h1>a<h2 id="h">b</h2>`,[]string{"h1"}},
+	{`<a href="/1">one<a href="/2" id="l">two</a>`,[]string{"a"}},
+	{`<div>a<img class="i" src="/x.png">b</div>`,[]string{".i"}},
+	{`<div>a<br>b</div><p id="x">x</p>`,[]string{"div"}},
+	{`<div id="x">unclosed <b>bold`,[]string{"#x"}},
+	{`<div id="a"></div><p>x</p>`,[]string{"#a","p"}},
+	{`<p>x</p>`,[]string{"#missing"}},
+	{`<div id="a"/><p>x</p>`,[]string{"#a"}},
+	{`<div id="a"/><p>x</p>`,[]string{"#a","p"}},
+	{`<div><span/><b id="b">x</b></div>`,[]string{"span","b"}},
+	{`<svg><circle id="c"/><g id="g">x</g></svg><p>y</p>`,[]string{"#c"}},
+	{`<svg><circle/><g id="g">x</g></svg>`,[]string{"circle","g"}},
+	{`<svg id="s"/><p>y</p>`,[]string{"#s"}},
+	{`<svg><foreignObject><div id="d"/><p>x</p></foreignObject></svg>`,[]string{"#d"}},
+	{`<math><mi id="m"/><mn>1</mn></math>`,[]string{"#m"}},
+}
+
+func TestStreamFragments(t *testing.T) {
+	for _,tt := range streamTests {
+		s := serve(t,page(tt.doc))
+		want,werr := query(t,s.URL,&QueryElement{Selectors:tt.sels,Tag:"-"})
+		q := &QueryElement{Selectors:tt.sels,Tag:"-",Element:container.NewElement()}
+		err := StreamFragments(context.Background(),http.DefaultClient,get(t,s.URL),[]*QueryElement{q})
+		if got := q.Element.Get(); got!=want || (err==nil)!=(werr==nil) {
+			t.Errorf("%s %v: got %q, %v, want %q, %v",tt.doc,tt.sels,got,err,want,werr)
+		}
+	}
+}
+
+func TestImplyEnd(t *testing.T) {
+	tests := []struct{ stack,name,want string }{
+		{"div p","div","div"},
+		{"button p","div","button"},
+		{"p button","div","p button"},
+		{"ul li p","li","ul"},
+		{"ul li ul","li","ul li ul"},
+		{"ul li div","li","ul"},
+		{"table tr td","td","table tr"},
+		{"table tr td table","td","table tr td table"},
+		{"table tbody tr td","tr","table tbody"},
+		{"table tbody tr td","tbody","table"},
+		{"select option","optgroup","select"},
+		{"h1","h2",""},
+		{"p a b","a","p"},
+		{"div span","span","div span"},
+	}
+	for _,tt := range tests {
+		if got := strings.Join(implyEnd(strings.Fields(tt.stack),tt.name)," "); got!=tt.want { t.Errorf("%s <%s>: got %q, want %q",tt.stack,tt.name,got,tt.want) }
+	}
+}
+
+func TestStreamStopsEarly(t *testing.T) {
+	finished := make(chan bool,1)
+	s := serve(t,func(w http.ResponseWriter,r *http.Request) {
+		w.Header().Set("Content-Type","text/html")
+		// the first 1024 bytes are read to detect the encoding.
+		fmt.Fprint(w,`<div id="x">top</div><!--`+strings.Repeat("x",1024)+`-->`)
+		w.(http.Flusher).Flush()
+		select {
+		case <- r.Context().Done():
+			finished <- false
+		case <- time.After(5*time.Second):
+			fmt.Fprint(w,`<p>rest</p>`)
+			finished <- true
+		}
+	})
+	q := &QueryElement{Selectors:[]string{"#x"},Element:container.NewElement()}
+	if e := StreamFragments(context.Background(),http.DefaultClient,get(t,s.URL),[]*QueryElement{q}); e!=nil { t.Fatal(e) }
+	if got := q.Element.Get(); got!="top" { t.Errorf("got %q",got) }
+	if <- finished { t.Errorf("the whole page was downloaded") }
+}
+
+// a page of about 1 MB, whose first element is wanted.
+func bigPage() http.HandlerFunc {
+	var b strings.Builder
+	b.WriteString(`<html><body><div id="head"><h1>Title</h1></div>`)
+	for b.Len()<1<<20 { b.WriteString(`<div class="item"><p>Lorem ipsum <a href="/x">dolor</a> sit amet.</p></div>`) }
+	return page(b.String())
+}
+
+func benchmarkFragments(b *testing.B,get func(r *http.Request,qs []*QueryElement) error) {
+	s := serve(b,bigPage())
+	for i := 0; i<b.N; i++ {
+		r,_ := http.NewRequest("GET",s.URL,nil)
+		q := &QueryElement{Selectors:[]string{"#head","h1"},Element:container.NewElement()}
+		if e := get(r,[]*QueryElement{q}); e!=nil { b.Fatal(e) }
+	}
+}
+
+func BenchmarkGetFragments(b *testing.B) {
+	benchmarkFragments(b,func(r *http.Request,qs []*QueryElement) error { return GetFragments(http.DefaultClient,r,qs) })
+}
+
+func BenchmarkStreamFragments(b *testing.B) {
+	benchmarkFragments(b,func(r *http.Request,qs []*QueryElement) error {
+		return StreamFragments(context.Background(),http.DefaultClient,r,qs)
+	})
+}
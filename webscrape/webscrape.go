@@ -14,11 +14,16 @@ limitations under the License.
 package webscrape
 
 import (
+	"context"
 	"net/http"
 	"github.com/maxymania/scrapland/container"
 	"github.com/maxymania/scrapland/htmlscrape"
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 	"bytes"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
 )
 
 type HttpClient interface{
@@ -26,54 +31,220 @@ type HttpClient interface{
 }
 
 
+// If QueryElement.Tag is TagMarkdown, the fragment is converted to Markdown.
+const TagMarkdown = "#md"
+
 type QueryElement struct{
+	// The selectors are applied in turn, each one to the result of the previous
+	// one. Their syntax depends on the type of the document: htmlscrape.LurkFor
+	// for HTML, XPath for XML and JSONPath ("$...") or JMESPath for JSON.
 	Selectors []string
+
+	// Receives the content for text/templates (see container.Container),
+	// which insert it as it is. The content is HTML, that is neither
+	// sanitized nor escaped, so an html/template (like the HTMLContainer)
+	// would show it escaped, as text: Use Fragment there.
 	Element   *container.Element
 
+	// Like Element, but for html/templates (see container.HTMLContainer).
+	// The content is sanitized with htmlscrape.Sanitize before it is offered,
+	// unless Trusted is true.
+	Fragment  *container.Fragment
+
+	// If Trusted is true, the content is offered as it is: Fragments are not
+	// sanitized, Markdown and JSON values are not HTML-escaped.
+	Trusted   bool
+
 	// If not empty, Tag contains the tag of the element, which should
 	// enclose the content. In that turn, all other HTML tags are stripped,
 	// leaving only the bare tags.
 	// If Tag is "-", it behaves much like Tag=="", except that the surrounding,
 	// html-tag is also present in the output.
+	// If Tag is TagMarkdown, the fragment (including the surrounding tag) is
+	// converted to Markdown (see htmlscrape.Markdown). As the Markdown is text,
+	// it is HTML-escaped, so that a page shows it verbatim. Set Trusted to get
+	// the bare Markdown (eg. for chat bots), but never insert it into a page
+	// then, as it may contain raw HTML.
 	Tag      string
+
+	// The converter used, if Tag is TagMarkdown. If nil, the default settings
+	// are used.
+	Markdown *htmlscrape.Markdown
+
+	// The options for the text rendering, if Tag is neither "" nor "-".
+	// If nil, htmlscrape.DefaultText is used.
+	Text     *htmlscrape.TextOptions
+
+	// If Article is true, the main content (see htmlscrape.FindContent) of
+	// the element selected by Selectors is used instead of the element itself.
+	// This is useful to populate container.Page.Main from arbitrary sites.
+	Article  bool
+
+	// By default, all URLs within the fragment are resolved against the URL
+	// of the document (taking <base href> into account). If Raw is true, the
+	// URLs are left as they are.
+	Raw      bool
+
+	// If not nil, every (absolutized) URL within the fragment is passed through
+	// URLMap, eg. htmlscrape.ProxyURL("/proxy/").
+	URLMap   func(string)string
+
+	// The transforms are applied in order to the extracted fragment, before
+	// it is rendered, eg. htmlscrape.Remove(".ad").
+	Transforms []htmlscrape.Transf
+
+	// The content, that is used instead of the fragment, if the query
+	// fails (the request failed, or the element was not found).
+	Fallback string
+
+	// If not nil and the query fails, ErrorTemplate is executed with the
+	// *QueryError and its output is used instead of Fallback.
+	ErrorTemplate *template.Template
+
+	// If Required is true, a failure of this query fails all QueryElements
+	// of the same GetFragments call. Use it for the elements, without which
+	// the other fragments are meaningless (eg. the main content).
+	Required bool
+
 	data      string
 }
 
-func GetFragments(hc HttpClient, r *http.Request, qs []*QueryElement) {
-	defer func(){
+func (q *QueryElement) fail(e error) *QueryError {
+	qe := &QueryError{q,e}
+	q.data = q.Fallback
+	if q.ErrorTemplate!=nil {
 		buf := &bytes.Buffer{}
-		for _,q := range qs {
-			buf.WriteString(q.data)
-			if q.Element!=nil {
-				q.Element.Offer(buf.String())
-				buf.Reset()
+		if q.ErrorTemplate.Execute(buf,qe)==nil { q.data = buf.String() }
+	}
+	return qe
+}
+
+// offers the data of the queries to their Elements and Fragments.
+func offer(qs []*QueryElement) {
+	buf := &bytes.Buffer{}
+	for _,q := range qs {
+		buf.WriteString(q.data)
+		if q.Element==nil && q.Fragment==nil { continue }
+		if q.Element!=nil { q.Element.Offer(buf.String()) }
+		if q.Fragment!=nil {
+			if q.Trusted {
+				q.Fragment.Offer(htmltemplate.HTML(buf.String()))
+			} else {
+				q.Fragment.Offer(sanitize(buf.String()))
 			}
-			//q.Element.Offer(q.data)
 		}
-	}()
+		buf.Reset()
+	}
+}
+
+// parses s as HTML fragment and removes the unsafe parts (see htmlscrape.Sanitize).
+func sanitize(s string) htmltemplate.HTML {
+	body := &html.Node{Type:html.ElementNode,Data:"body",DataAtom:atom.Body}
+	nn,e := html.ParseFragment(strings.NewReader(s),body)
+	if e!=nil { return "" }
+	for _,n := range nn { body.AppendChild(n) }
+	htmlscrape.Walk(body,htmlscrape.Sanitize)
+	buf := &bytes.Buffer{}
+	htmlscrape.Render(buf,body)
+	return htmltemplate.HTML(buf.String())
+}
+
+// a parsed document.
+type fetched struct{
+	d Document
+	e error
+}
+
+func fetch(hc HttpClient, r *http.Request) fetched {
 	resp,e := hc.Do(r)
-	if e!=nil { return }
-	p,e := html.Parse(resp.Body)
-	if e!=nil { return }
-	for _,q := range qs {
-		qe := p
-		for _,s := range q.Selectors {
-			qe = htmlscrape.LurkFor(qe,s)
-		}
-		if qe==nil { continue }
-		if q.Tag=="" {
-			buf := &bytes.Buffer{}
-			htmlscrape.Render(buf,qe)
-			q.data = buf.String()
-		} else if q.Tag=="-" {
-			buf := &bytes.Buffer{}
-			html.Render(buf,qe)
-			q.data = buf.String()
-		} else {
-			t := htmlscrape.ExtractText(qe)
-			q.data = "<"+q.Tag+">"+html.EscapeString(t)+"</"+q.Tag+">"
-		}
+	if e!=nil { return fetched{e:e} }
+	defer resp.Body.Close()
+	if resp.StatusCode<200 || resp.StatusCode>299 {
+		return fetched{e:&StatusError{resp.StatusCode,resp.Status}}
 	}
+	u := r.URL
+	if resp.Request!=nil { u = resp.Request.URL }
+	d,e := Parse(resp.Body,resp.Header.Get("Content-Type"),u)
+	return fetched{d,e}
+}
+
+/*
+ Performs the request r and extracts the fragments described by qs. Every
+ Element (and Fragment) is offered its content, even if the request fails
+ (the Fallback content in that case). The content of QueryElements without an
+ Element or Fragment is prepended to the content of the next QueryElement,
+ that has one.
+
+ If any QueryElement failed, the returned error is a *Report.
+ */
+func GetFragments(hc HttpClient, r *http.Request, qs []*QueryElement) error {
+	return GetFragmentsContext(r.Context(),hc,r,qs)
+}
+
+/*
+ Like GetFragments, but the request is performed with the context ctx. Once
+ ctx is done (eg. its deadline has been exceeded), GetFragmentsContext offers
+ the Fallback content to the Elements and returns, even if the HttpClient
+ does not honor the context.
+ */
+func GetFragmentsContext(ctx context.Context, hc HttpClient, r *http.Request, qs []*QueryElement) error {
+	if ctx.Done()==nil {
+		return fragments(r,qs,fetch(hc,r))
+	}
+	return fragments(r,qs,start(hc,r.WithContext(ctx)).await(ctx))
+}
+
+// a fetch running in the background.
+type call struct{
+	done chan struct{}
+	f    fetched
 }
 
+func start(hc HttpClient, r *http.Request) *call {
+	return startFunc(func() fetched { return fetch(hc,r) })
+}
+
+func startFunc(fn func() fetched) *call {
+	c := &call{done:make(chan struct{})}
+	go func(){
+		c.f = fn()
+		close(c.done)
+	}()
+	return c
+}
+
+// waits for the result of the fetch or until ctx is done.
+func (c *call) await(ctx context.Context) fetched {
+	select {
+	case <- c.done:
+		return c.f
+	case <- ctx.Done():
+		return fetched{e:ctx.Err()}
+	}
+}
 
+func fragments(r *http.Request, qs []*QueryElement, f fetched) error {
+	defer offer(qs)
+	rep := &Report{Request:r}
+	for _,q := range qs { q.data = "" }
+	if f.e!=nil {
+		for _,q := range qs { rep.Errors = append(rep.Errors,q.fail(f.e)) }
+		return rep
+	}
+	var required error
+	for _,q := range qs {
+		data,e := f.d.Query(q)
+		if e==nil {
+			q.data = data
+			continue
+		}
+		rep.Errors = append(rep.Errors,q.fail(e))
+		if q.Required && required==nil { required = e }
+	}
+	if required!=nil {
+		rep.Errors = rep.Errors[:0]
+		for _,q := range qs { rep.Errors = append(rep.Errors,q.fail(required)) }
+	}
+	if len(rep.Errors)==0 { return nil }
+	return rep
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2015 Simon Schmidt
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webscrape
+
+import (
+	"github.com/maxymania/scrapland/container"
+	"github.com/maxymania/scrapland/htmlscrape"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+// starts a server, that is closed at the end of the test.
+func serve(t testing.TB,h http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	s := httptest.NewServer(h)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// serves the HTML page src.
+func page(src string) http.HandlerFunc {
+	return func(w http.ResponseWriter,r *http.Request) {
+		w.Header().Set("Content-Type","text/html; charset=utf-8")
+		io.WriteString(w,src)
+	}
+}
+
+// waits until cond is true, failing the test after a few seconds.
+func waitFor(t *testing.T,cond func() bool) {
+	t.Helper()
+	for start := time.Now(); !cond(); time.Sleep(time.Millisecond) {
+		if time.Since(start)>5*time.Second { t.Fatal("timed out") }
+	}
+}
+
+func get(t *testing.T,u string) *http.Request {
+	t.Helper()
+	r,e := http.NewRequest("GET",u,nil)
+	if e!=nil { t.Fatal(e) }
+	return r
+}
+
+// runs GetFragments with a single query and returns the offered content.
+func query(t *testing.T,u string,q *QueryElement) (string,error) {
+	t.Helper()
+	q.Element = container.NewElement()
+	e := GetFragments(http.DefaultClient,get(t,u),[]*QueryElement{q})
+	return q.Element.Get(),e
+}
+
+func TestTransforms(t *testing.T) {
+	s := serve(t,page(`<div id="c"><p>a</p><div class="ad">buy</div><b>b</b></div>`))
+	got,e := query(t,s.URL,&QueryElement{Selectors:[]string{"#c"},Transforms:[]htmlscrape.Transf{htmlscrape.Remove(".ad"),htmlscrape.Rename("b","strong")}})
+	if e!=nil { t.Fatal(e) }
+	if want := `<p>a</p><strong>b</strong>`; got!=want { t.Errorf("got %s, want %s",got,want) }
+}
+
+func TestTagMode(t *testing.T) {
+	s := serve(t,page(`<div id="c"><p>a &lt;b&gt;   <i>c</i></p><ul><li>x</li></ul><script>bad()</script></div>`))
+	got,e := query(t,s.URL,&QueryElement{Selectors:[]string{"#c"},Tag:"pre"})
+	if e!=nil { t.Fatal(e) }
+	if want := "<pre>a &lt;b&gt; c\n\n- x</pre>"; got!=want { t.Errorf("got %q, want %q",got,want) }
+}
+
+func TestMarkdownMode(t *testing.T) {
+	s := serve(t,page(`<div id="c"><p>a <b>b</b> <code>&lt;script&gt;</code></p></div>`))
+	got,e := query(t,s.URL,&QueryElement{Selectors:[]string{"#c"},Tag:TagMarkdown})
+	if e!=nil { t.Fatal(e) }
+	if want := "a **b** `&lt;script&gt;`\n"; got!=want { t.Errorf("got %q, want %q",got,want) }
+
+	got,_ = query(t,s.URL,&QueryElement{Selectors:[]string{"#c"},Tag:TagMarkdown,Trusted:true})
+	if want := "a **b** `<script>`\n"; got!=want { t.Errorf("Trusted: got %q, want %q",got,want) }
+}
+
+func TestReport(t *testing.T) {
+	s := serve(t,func(w http.ResponseWriter,r *http.Request) {
+		if r.URL.Path=="/gone" {
+			http.Error(w,"gone",http.StatusGone)
+			return
+		}
+		page(`<div id="a">A</div>`)(w,r)
+	})
+	found := &QueryElement{Selectors:[]string{"#a"},Element:container.NewElement()}
+	missing := &QueryElement{Selectors:[]string{"#b"},Element:container.NewElement(),Fallback:"n/a"}
+	e := GetFragments(http.DefaultClient,get(t,s.URL),[]*QueryElement{found,missing})
+	var rep *Report
+	if !errors.As(e,&rep) { t.Fatalf("got %v, want a *Report",e) }
+	if rep.Err(found)!=nil || !errors.Is(rep.Err(missing),ErrNotFound) { t.Errorf("got %v",rep) }
+	if found.Element.Get()!="A" || missing.Element.Get()!="n/a" { t.Errorf("got %q and %q",found.Element.Get(),missing.Element.Get()) }
+
+	// a failed request fails every query.
+	tmpl := template.Must(template.New("").Parse(`<p class="error">unavailable: {{.Err}}</p>`))
+	q := &QueryElement{Selectors:[]string{"#a"},Element:container.NewElement(),ErrorTemplate:tmpl}
+	e = GetFragments(http.DefaultClient,get(t,s.URL+"/gone"),[]*QueryElement{q})
+	var se *StatusError
+	if !errors.As(e,&se) || se.StatusCode!=http.StatusGone { t.Errorf("got %v, want a StatusError 410",e) }
+	if got := q.Element.Get(); !strings.HasPrefix(got,`<p class="error">unavailable: webscrape: unexpected status 410`) { t.Errorf("got %q",got) }
+
+	q = &QueryElement{Selectors:[]string{"#a"},Element:container.NewElement(),Fallback:"down"}
+	if GetFragments(http.DefaultClient,get(t,"http://127.0.0.1:1/"),[]*QueryElement{q})==nil { t.Errorf("no error for a failed request") }
+	if q.Element.Get()!="down" { t.Errorf("got %q, want the Fallback",q.Element.Get()) }
+}
+
+func TestRequired(t *testing.T) {
+	s := serve(t,page(`<div id="a">A</div>`))
+	a := &QueryElement{Selectors:[]string{"#a"},Element:container.NewElement(),Fallback:"-"}
+	main := &QueryElement{Selectors:[]string{"#main"},Element:container.NewElement(),Required:true,Fallback:"-"}
+	e := GetFragments(http.DefaultClient,get(t,s.URL),[]*QueryElement{a,main})
+	var rep *Report
+	if !errors.As(e,&rep) || len(rep.Errors)!=2 { t.Fatalf("got %v, want two errors",e) }
+	if a.Element.Get()!="-" { t.Errorf("got %q, want the Fallback, as a Required query failed",a.Element.Get()) }
+}
+
+// counts the response bodies, that are closed.
+type closeCounter struct{
+	hc     HttpClient
+	closed int
+}
+type countedBody struct{
+	io.ReadCloser
+	c *closeCounter
+}
+func (b countedBody) Close() error {
+	b.c.closed++
+	return b.ReadCloser.Close()
+}
+func (c *closeCounter) Do(r *http.Request) (*http.Response,error) {
+	resp,e := c.hc.Do(r)
+	if e==nil { resp.Body = countedBody{resp.Body,c} }
+	return resp,e
+}
+
+func TestBodyClosed(t *testing.T) {
+	s := serve(t,func(w http.ResponseWriter,r *http.Request) {
+		if r.URL.Path=="/err" { w.WriteHeader(500) }
+		page(`<p>x</p>`)(w,r)
+	})
+	c := &closeCounter{hc:http.DefaultClient}
+	for _,p := range []string{"/","/err"} {
+		GetFragments(c,get(t,s.URL+p),[]*QueryElement{{Selectors:[]string{"p"},Element:container.NewElement()}})
+	}
+	if c.closed!=2 { t.Errorf("closed %d bodies, want 2",c.closed) }
+}
+
+func TestFragment(t *testing.T) {
+	s := serve(t,page(`<div id="c"><p onclick="x()">a</p><script>evil()</script><a href="javascript:x">b</a></div>`))
+	q := &QueryElement{Selectors:[]string{"#c"},Element:container.NewElement(),Fragment:container.NewFragment()}
+	tq := &QueryElement{Selectors:[]string{"#c"},Fragment:container.NewFragment(),Trusted:true}
+	if e := GetFragments(http.DefaultClient,get(t,s.URL),[]*QueryElement{q,tq}); e!=nil { t.Fatal(e) }
+	raw := `<p onclick="x()">a</p><script>evil()</script><a href="javascript:x">b</a>`
+	if got := q.Element.Get(); got!=raw { t.Errorf("Element: got %s",got) }
+	if got := q.Fragment.Get(); got!=`<p>a</p><a>b</a>` { t.Errorf("Fragment: got %s",got) }
+	if got := tq.Fragment.Get(); string(got)!=raw { t.Errorf("trusted Fragment: got %s",got) }
+}